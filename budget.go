@@ -0,0 +1,61 @@
+package faildep
+
+import (
+	"sync"
+	"time"
+)
+
+// budget limits extra attempts, like hedges and retries, as a ratio of calls.
+// Every call deposits ratio token and every extra attempt withdraws one token,
+// minPerSecond tokens are refilled to a reserve every second so low traffic can still retry.
+type budget struct {
+	lock         sync.Mutex
+	ratio        float64
+	max          float64
+	balance      float64
+	minPerSecond float64
+	reserve      float64
+	lastRefill   time.Time
+}
+
+func newBudget(ratio float64, max float64, minPerSecond float64) *budget {
+	return &budget{
+		ratio:        ratio,
+		max:          max,
+		minPerSecond: minPerSecond,
+		reserve:      minPerSecond,
+		lastRefill:   time.Now(),
+	}
+}
+
+func (b *budget) deposit() {
+	b.lock.Lock()
+	b.balance += b.ratio
+	if b.balance > b.max {
+		b.balance = b.max
+	}
+	b.lock.Unlock()
+}
+
+func (b *budget) withdraw() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.balance >= 1 {
+		b.balance--
+		return true
+	}
+	if b.minPerSecond <= 0 {
+		return false
+	}
+	now := time.Now()
+	b.reserve += now.Sub(b.lastRefill).Seconds() * b.minPerSecond
+	if b.reserve > b.minPerSecond {
+		b.reserve = b.minPerSecond
+	}
+	b.lastRefill = now
+	if b.reserve < 1 {
+		return false
+	}
+	b.reserve--
+	return true
+}
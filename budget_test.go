@@ -0,0 +1,49 @@
+package faildep
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestBudget_ratioAndReserve(t *testing.T) {
+	b := newBudget(0.5, 2, 0)
+	assert.False(t, b.withdraw())
+	b.deposit()
+	b.deposit()
+	assert.True(t, b.withdraw())
+	assert.False(t, b.withdraw())
+	for i := 0; i < 10; i++ {
+		b.deposit()
+	}
+	assert.True(t, b.withdraw())
+	assert.True(t, b.withdraw())
+	assert.False(t, b.withdraw())
+
+	b = newBudget(0, 10, 2)
+	assert.True(t, b.withdraw())
+	assert.True(t, b.withdraw())
+	assert.False(t, b.withdraw())
+	time.Sleep(600 * time.Millisecond)
+	assert.True(t, b.withdraw())
+}
+
+func TestRetryBudget_exhausted(t *testing.T) {
+	var events []Event
+	f := NewFailDepStatic("testRetryBudget", []string{"1", "2", "3"},
+		WithRetry(2, 2, 0, 0, NoBackoff),
+		WithRetryBudget(0, 1),
+		WithEventListener(func(e Event) {
+			events = append(events, e)
+		}),
+	)
+	var count int
+	err := f.Do(func(node *Resource) error {
+		count++
+		return testNetError{}
+	})
+	assert.EqualError(t, err, "realError")
+	assert.Equal(t, 2, count)
+	assert.Len(t, events, 1)
+	assert.Equal(t, RetryBudgetExhausted, events[0].Type)
+}
@@ -0,0 +1,165 @@
+package faildep
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// CanaryTag indicate resource which receive canary traffic.
+const CanaryTag = "canary"
+
+// WithCanary configure canary traffic splitting config.
+//
+// Default: Canary is disabled, resources tagged `canary` are used as normal resources.
+//
+// - percent indicate percentage of calls sent to resources tagged `canary`, in [0, 100], value out of range is clamped.
+// - maxFailRatioDelta indicate how much canary failure ratio can beyond baseline's, percent will back off to 0 when beyond it.
+// - minRequests indicate minimum canary calls before comparing failure ratio.
+func WithCanary(percent float64, maxFailRatioDelta float64, minRequests uint64) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.canary = &canary{
+			maxFailRatioDelta: maxFailRatioDelta,
+			minRequests:       minRequests,
+		}
+		f.canary.setPercent(percent)
+	}
+}
+
+// SetCanaryPercent changes percentage of calls sent to canary resources at runtime, percent is clamped to [0, 100],
+// and resets canary stats for new comparison.
+// It only takes effect when canary is enabled by `WithCanary`.
+func (f *FailDep) SetCanaryPercent(percent float64) {
+	if f.canary == nil {
+		return
+	}
+	f.canary.reset()
+	f.canary.setPercent(percent)
+}
+
+// CanaryStats returns call stats of canary group and baseline group.
+func (f *FailDep) CanaryStats() (canaryStats GroupStats, baselineStats GroupStats) {
+	if f.canary == nil {
+		return
+	}
+	return f.canary.canary.snapshot(), f.canary.baseline.snapshot()
+}
+
+// GroupStats present call stats of a resource group.
+type GroupStats struct {
+	// Requests present call count.
+	Requests uint64 `json:"requests"`
+	// Failures present failed call count.
+	Failures uint64 `json:"failures"`
+	// AvgLatency present average call time.
+	AvgLatency time.Duration `json:"avgLatency"`
+}
+
+// FailRatio returns ratio of failed calls, it's 0 when there is no call.
+func (s GroupStats) FailRatio() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Failures) / float64(s.Requests)
+}
+
+type groupStats struct {
+	requests     uint64
+	failures     uint64
+	latencyTotal int64
+}
+
+func (g *groupStats) record(rt time.Duration, failed bool) {
+	atomic.AddUint64(&g.requests, 1)
+	if failed {
+		atomic.AddUint64(&g.failures, 1)
+	}
+	atomic.AddInt64(&g.latencyTotal, int64(rt))
+}
+
+func (g *groupStats) reset() {
+	atomic.StoreUint64(&g.requests, 0)
+	atomic.StoreUint64(&g.failures, 0)
+	atomic.StoreInt64(&g.latencyTotal, 0)
+}
+
+func (g *groupStats) snapshot() GroupStats {
+	s := GroupStats{
+		Requests: atomic.LoadUint64(&g.requests),
+		Failures: atomic.LoadUint64(&g.failures),
+	}
+	if s.Requests > 0 {
+		s.AvgLatency = time.Duration(atomic.LoadInt64(&g.latencyTotal) / int64(s.Requests))
+	}
+	return s
+}
+
+type canary struct {
+	percent           uint64
+	maxFailRatioDelta float64
+	minRequests       uint64
+	canary            groupStats
+	baseline          groupStats
+}
+
+// setPercent stores percent clamped to [0, 100], NaN is treated as 0.
+func (c *canary) setPercent(percent float64) {
+	switch {
+	case math.IsNaN(percent) || percent < 0:
+		percent = 0
+	case percent > 100:
+		percent = 100
+	}
+	atomic.StoreUint64(&c.percent, math.Float64bits(percent))
+}
+
+func (c *canary) takePercent() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.percent))
+}
+
+func (c *canary) reset() {
+	c.canary.reset()
+	c.baseline.reset()
+}
+
+// sample decides whether a call goes to canary group.
+func (c *canary) sample() bool {
+	percent := c.takePercent()
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+// servers returns resources of call's group,
+// call falls back to baseline group when there is no available canary resource.
+func (c *canary) servers(ctx *executionContext, servers ResourceList) ResourceList {
+	if ctx.canary {
+		canaryServers := filterResource(servers, func(node Resource) bool {
+			return node.HasTag(CanaryTag)
+		})
+		if len(canaryServers) > 0 {
+			return canaryServers
+		}
+		ctx.canary = false
+	}
+	return filterResource(servers, func(node Resource) bool {
+		return !node.HasTag(CanaryTag)
+	})
+}
+
+// record records call result into its group,
+// and returns true when canary percent backs off to 0.
+func (c *canary) record(inCanary bool, rt time.Duration, err error) bool {
+	if !inCanary {
+		c.baseline.record(rt, err != nil)
+		return false
+	}
+	c.canary.record(rt, err != nil)
+	canaryStats := c.canary.snapshot()
+	if canaryStats.Requests < c.minRequests {
+		return false
+	}
+	if canaryStats.FailRatio() <= c.baseline.snapshot().FailRatio()+c.maxFailRatioDelta {
+		return false
+	}
+	return atomic.SwapUint64(&c.percent, math.Float64bits(0)) != math.Float64bits(0)
+}
@@ -0,0 +1,68 @@
+package faildep
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestCanary_splitAndBackOff(t *testing.T) {
+	f := NewFailDepStaticResources("testCanary", ResourceList{
+		{Server: "stable1"},
+		{Server: "stable2"},
+		{Server: "new", Tags: []string{CanaryTag}},
+	}, WithCanary(100, 0.1, 5))
+
+	for i := 0; i < 5; i++ {
+		err := f.Do(func(node *Resource) error {
+			assert.Equal(t, "new", node.Server)
+			return testNetError{}
+		})
+		assert.Error(t, err)
+	}
+	canaryStats, baselineStats := f.CanaryStats()
+	assert.Equal(t, uint64(5), canaryStats.Requests)
+	assert.Equal(t, uint64(5), canaryStats.Failures)
+	assert.Equal(t, uint64(0), baselineStats.Requests)
+
+	for i := 0; i < 10; i++ {
+		err := f.Do(func(node *Resource) error {
+			assert.NotEqual(t, "new", node.Server)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	_, baselineStats = f.CanaryStats()
+	assert.Equal(t, uint64(10), baselineStats.Requests)
+
+	f.SetCanaryPercent(100)
+	canaryStats, _ = f.CanaryStats()
+	assert.Equal(t, uint64(0), canaryStats.Requests)
+	err := f.Do(func(node *Resource) error {
+		assert.Equal(t, "new", node.Server)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestCanary_fallbackWithoutCanaryResource(t *testing.T) {
+	f := NewFailDepStatic("testCanaryFallback", []string{"1", "2"}, WithCanary(100, 0.1, 5))
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	canaryStats, baselineStats := f.CanaryStats()
+	assert.Equal(t, uint64(0), canaryStats.Requests)
+	assert.Equal(t, uint64(1), baselineStats.Requests)
+}
+
+func TestCanary_percentClamped(t *testing.T) {
+	f := NewFailDepStatic("testCanaryClamp", []string{"1"}, WithCanary(150, 0.1, 10))
+	assert.Equal(t, float64(100), f.canary.takePercent())
+	f.SetCanaryPercent(-5)
+	assert.Equal(t, float64(0), f.canary.takePercent())
+	f.SetCanaryPercent(math.NaN())
+	assert.Equal(t, float64(0), f.canary.takePercent())
+	f.SetCanaryPercent(30)
+	assert.Equal(t, float64(30), f.canary.takePercent())
+}
@@ -0,0 +1,188 @@
+package faildep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"syscall"
+)
+
+// ClassifierBuilder builds response classifier from rules,
+// rules are checked in order and the first matched rule decides response type.
+//
+// Rules match error with `errors.Is` and `errors.As`, so they work on wrapped errors, e.g.
+//
+//	classifier := NewClassifier().
+//		Is(sql.ErrNoRows, Fail).
+//		As(new(*net.OpError), Fail|Breakable|Retriable).
+//		Fallback(NetworkErrorClassification).
+//		Build()
+type ClassifierBuilder struct {
+	rules    []classifyRule
+	fallback func(err error) RepType
+}
+
+type classifyRule struct {
+	match func(err error) bool
+	typ   RepType
+}
+
+// NewClassifier returns an empty ClassifierBuilder, whose classifier returns `OK` for nil error and `Fail` for others.
+func NewClassifier() *ClassifierBuilder {
+	return &ClassifierBuilder{}
+}
+
+// Is adds rule which matches when `errors.Is(err, target)`.
+func (b *ClassifierBuilder) Is(target error, typ RepType) *ClassifierBuilder {
+	return b.Match(func(err error) bool {
+		return errors.Is(err, target)
+	}, typ)
+}
+
+// As adds rule which matches when `errors.As(err, target)`,
+// target should be a non-nil pointer to a type implements error or any interface like `errors.As`, e.g. `new(*net.OpError)`.
+// It panics when target is invalid.
+func (b *ClassifierBuilder) As(target interface{}, typ RepType) *ClassifierBuilder {
+	targetType := asTargetType(target)
+	return b.Match(func(err error) bool {
+		return errors.As(err, reflect.New(targetType).Interface())
+	}, typ)
+}
+
+// Match adds rule which matches when predicate returns true, predicate is never called with nil error.
+func (b *ClassifierBuilder) Match(predicate func(err error) bool, typ RepType) *ClassifierBuilder {
+	b.rules = append(b.rules, classifyRule{
+		match: predicate,
+		typ:   typ,
+	})
+	return b
+}
+
+// Fallback configure classifier used when no rule matches.
+//
+// Default: error matches no rule is classified as `Fail`.
+func (b *ClassifierBuilder) Fallback(classifier func(err error) RepType) *ClassifierBuilder {
+	b.fallback = classifier
+	return b
+}
+
+// Build returns classifier can be used with `WithResponseClassifier`.
+func (b *ClassifierBuilder) Build() func(err error) RepType {
+	rules := make([]classifyRule, len(b.rules))
+	copy(rules, b.rules)
+	fallback := b.fallback
+	return func(err error) RepType {
+		if err == nil {
+			return OK
+		}
+		for _, rule := range rules {
+			if rule.match(err) {
+				return rule.typ
+			}
+		}
+		if fallback != nil {
+			return fallback(err)
+		}
+		return Fail
+	}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func asTargetType(target interface{}) reflect.Type {
+	if target == nil {
+		panic("faildep: As target cannot be nil")
+	}
+	typ := reflect.TypeOf(target)
+	if typ.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("faildep: As target must be a non-nil pointer, got %s", typ))
+	}
+	elem := typ.Elem()
+	if elem.Kind() != reflect.Interface && !elem.Implements(errorType) {
+		panic(fmt.Sprintf("faildep: As target *%s must be interface or implement error", elem))
+	}
+	return elem
+}
+
+// networkClassification is the rule set of `NetworkErrorClassification`.
+var networkClassification = NewClassifier().
+	As(new(*PanicError), Fail|Breakable).
+	Is(syscall.ECONNREFUSED, Fail|Breakable|Retriable|Unsent).
+	As(new(*net.DNSError), Fail|Breakable|Retriable|Unsent).
+	Match(func(err error) bool {
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}, Fail|Breakable|Retriable|Unsent).
+	Is(syscall.ECONNRESET, Fail|Breakable|Retriable).
+	Is(net.ErrClosed, Fail|Breakable|Retriable).
+	Is(context.DeadlineExceeded, Fail|Breakable|Retriable).
+	Is(io.ErrUnexpectedEOF, Fail|Breakable|Retriable).
+	Match(func(err error) bool {
+		netErr, ok := findNetError(err)
+		return ok && netErr.Timeout()
+	}, Fail|Breakable|Retriable).
+	Match(func(err error) bool {
+		_, ok := findNetError(err)
+		return ok
+	}, Fail|Breakable).
+	Build()
+
+// findNetError returns the first net.Error in err chain,
+// `*url.Error` is skipped because it implements net.Error even if it wraps non-network error.
+func findNetError(err error) (net.Error, bool) {
+	for err != nil {
+		if _, ok := err.(*url.Error); !ok {
+			if netErr, ok := err.(net.Error); ok {
+				return netErr, true
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil, false
+}
+
+// walkErrors calls visit on err and every error it wraps, including errors joined by `Unwrap() []error`,
+// it stops and returns true when visit returns true.
+func walkErrors(err error, visit func(err error) bool) bool {
+	if err == nil {
+		return false
+	}
+	if visit(err) {
+		return true
+	}
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		return walkErrors(e.Unwrap(), visit)
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if walkErrors(inner, visit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorField returns exported field with given name of error struct, error can be struct or pointer to struct.
+// It's used to read driver error without importing driver.
+func errorField(err error, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(err)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+	field, ok := v.Type().FieldByName(name)
+	if !ok || field.PkgPath != "" {
+		return reflect.Value{}, false
+	}
+	return v.FieldByIndex(field.Index), true
+}
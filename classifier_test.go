@@ -0,0 +1,77 @@
+package faildep
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type testCodeError struct {
+	code int
+}
+
+func (e *testCodeError) Error() string {
+	return fmt.Sprintf("code %d", e.code)
+}
+
+func TestClassifierBuilder(t *testing.T) {
+	notFound := errors.New("not found")
+	classifier := NewClassifier().
+		Is(notFound, Fail).
+		As(new(*testCodeError), Fail|Breakable).
+		Match(func(err error) bool {
+			return err.Error() == "retry me"
+		}, Fail|Retriable).
+		Is(io.EOF, Fail|Breakable|Retriable).
+		Build()
+
+	assert.Equal(t, OK, classifier(nil))
+	assert.Equal(t, Fail, classifier(fmt.Errorf("query: %w", notFound)))
+	assert.Equal(t, Fail|Breakable, classifier(fmt.Errorf("query: %w", &testCodeError{code: 1})))
+	assert.Equal(t, Fail|Retriable, classifier(errors.New("retry me")))
+	assert.Equal(t, Fail, classifier(errors.New("unknown")))
+
+	// first matched rule wins
+	assert.Equal(t, Fail|Breakable, classifier(errors.Join(&testCodeError{code: 1}, io.EOF)))
+}
+
+func TestClassifierBuilder_fallback(t *testing.T) {
+	classifier := NewClassifier().
+		Is(io.EOF, Fail).
+		Fallback(func(err error) RepType {
+			return Fail | Retriable
+		}).
+		Build()
+	assert.Equal(t, Fail, classifier(io.EOF))
+	assert.Equal(t, Fail|Retriable, classifier(errors.New("unknown")))
+}
+
+func TestClassifierBuilder_invalidAs(t *testing.T) {
+	assert.Panics(t, func() {
+		NewClassifier().As(testCodeError{}, Fail)
+	})
+	assert.Panics(t, func() {
+		NewClassifier().As(new(int), Fail)
+	})
+}
+
+func TestNetworkErrorClassification_wrapped(t *testing.T) {
+	refused := &url.Error{Op: "Get", URL: "http://1", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}
+	assert.Equal(t, Fail|Breakable|Retriable|Unsent, NetworkErrorClassification(fmt.Errorf("call: %w", refused)))
+	assert.Equal(t, Fail|Breakable|Retriable|Unsent, NetworkErrorClassification(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "x"}}))
+	assert.Equal(t, Fail|Breakable|Retriable, NetworkErrorClassification(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}))
+	assert.Equal(t, Fail|Breakable|Retriable, NetworkErrorClassification(fmt.Errorf("read: %w", net.ErrClosed)))
+	assert.Equal(t, Fail|Breakable|Retriable, NetworkErrorClassification(fmt.Errorf("read: %w", io.ErrUnexpectedEOF)))
+	assert.Equal(t, Fail|Breakable|Retriable, NetworkErrorClassification(context.DeadlineExceeded))
+	assert.Equal(t, Fail|Breakable|Retriable, NetworkErrorClassification(fmt.Errorf("call: %w", testNetError{})))
+	assert.Equal(t, Fail|Breakable, NetworkErrorClassification(&net.OpError{Op: "read", Err: errors.New("broken")}))
+	assert.Equal(t, Fail, NetworkErrorClassification(&url.Error{Op: "Get", URL: "x://1", Err: errors.New("unsupported protocol scheme")}))
+	assert.Equal(t, Fail, NetworkErrorClassification(errors.New("connection refused")))
+}
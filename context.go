@@ -1,19 +1,101 @@
 package faildep
 
+import (
+	"context"
+	"sync"
+)
+
 type executionContext struct {
+	ctx                context.Context
 	node               *Resource
-	attemptCount       uint
 	serverAttemptCount uint
+	affinityKey        string
+	tried              ResourceList
+	canary             bool
+	idempotent         bool
+	nonIdempotent      bool
+	attemptsLock       sync.Mutex
+	attempts           []Attempt
 }
 
-func (c *executionContext) incAttemptCount() {
-	c.attemptCount++
+// CallOption present option for single `Do` call.
+type CallOption func(c *executionContext)
+
+// WithAffinityKey gives call an affinity key,
+// calls with same key will be sent to same resource when sticky session is enabled by `WithStickySession`.
+func WithAffinityKey(key string) CallOption {
+	return func(c *executionContext) {
+		c.affinityKey = key
+	}
 }
 
-func (c *executionContext) incServerAttemptCount() {
-	c.serverAttemptCount++
+// WithIdempotent marks whether call is idempotent.
+//
+// Default: call is idempotent, it's retried as `WithRetry` configured.
+//
+// Non-idempotent call is only retried when response is classified as `Unsent` or `Redirect`,
+// e.g. connection refused or bulkhead rejection, and it's never hedged or mirrored to shadow resources.
+// Timeout after request has been sent is never retried for it.
+//
+// It takes precedence over idempotency reported by result classifier, e.g. method and header checked by `HTTPClassification`,
+// so POST call marked by `WithIdempotent(true)` is retried as idempotent call.
+func WithIdempotent(idempotent bool) CallOption {
+	return func(c *executionContext) {
+		c.idempotent = idempotent
+		c.nonIdempotent = !idempotent
+	}
+}
+
+func newExecutionContext(ctx context.Context, opts []CallOption) *executionContext {
+	c := &executionContext{ctx: ctx}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *executionContext) markTried(node Resource) {
+	if !c.tried.contains(node) {
+		c.tried = append(c.tried, node)
+	}
+}
+
+// canRetry returns whether request classified as repType can be redone,
+// request is non-idempotent when call is marked so, or classifier reports it and call isn't marked idempotent.
+func (c *executionContext) canRetry(repType RepType, nonIdempotent bool) bool {
+	if c.nonIdempotent || (nonIdempotent && !c.idempotent) {
+		return repType&Unsent == Unsent
+	}
+	return true
+}
+
+func (c *executionContext) callInfo() *CallInfo {
+	return &CallInfo{
+		Current:     c.node,
+		Tried:       c.tried,
+		AffinityKey: c.affinityKey,
+	}
+}
+
+// recordAttempt records executed attempt, it's safe to be called by hedged attempts concurrently.
+func (c *executionContext) recordAttempt(a Attempt) {
+	c.attemptsLock.Lock()
+	c.attempts = append(c.attempts, a)
+	c.attemptsLock.Unlock()
 }
 
-func (c *executionContext) resetAttemptCount() {
-	c.attemptCount = 0
+// exhausted returns error with given reason and attempts have been executed.
+func (c *executionContext) exhausted(reason error) error {
+	c.attemptsLock.Lock()
+	defer c.attemptsLock.Unlock()
+	attempts := make([]Attempt, len(c.attempts))
+	copy(attempts, c.attempts)
+	return &ExhaustedError{
+		Reason:   reason,
+		Attempts: attempts,
+	}
+}
+
+func (c *executionContext) incServerAttemptCount() {
+	c.serverAttemptCount++
 }
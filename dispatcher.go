@@ -1,29 +1,96 @@
 package faildep
 
 import (
+	"hash/fnv"
 	"math/rand"
+	"sync/atomic"
 )
 
 type dispatcher struct {
 	srvPicker ServerPicker
+	sticky    *stickyTable
+	exhausted ExhaustedPolicy
 }
 
+// ExhaustedPolicy present what to do when every available resource has been tried in one call.
+type ExhaustedPolicy int
+
+const (
+	// ExhaustedStartOver forgets tried resources and picks from all available resources again.
+	ExhaustedStartOver ExhaustedPolicy = iota
+	// ExhaustedFail stops picking and `Do` returns `MaxRetryError`.
+	ExhaustedFail
+	// ExhaustedReuseLeastFailed picks from tried resources which have least successive failures.
+	ExhaustedReuseLeastFailed
+)
+
 func newDispatcher() *dispatcher {
 	dist := &dispatcher{}
 	dist.srvPicker = P2CPick
 	return dist
 }
 
+// pick picks server for current execution,
+// sticky resource will be used first when call has affinity key,
+// and resources tried in current call are only picked again according to `ExhaustedPolicy`.
+func (d *dispatcher) pick(metrics Metrics, ctx *executionContext, servers ResourceList) (*Resource, error) {
+	if len(servers) == 0 {
+		return nil, AllResourceDownError
+	}
+	sticky := d.sticky != nil && ctx.affinityKey != ""
+	var node *Resource
+	if sticky && ctx.node == nil {
+		node = d.sticky.lookup(ctx.affinityKey, servers)
+	}
+	if node == nil {
+		candidates := excludeTried(ctx.tried, servers)
+		if len(candidates) == 0 {
+			switch d.exhausted {
+			case ExhaustedFail:
+				return nil, MaxRetryError
+			case ExhaustedReuseLeastFailed:
+				candidates = leastFailed(metrics, servers)
+			default:
+				ctx.tried = nil
+				candidates = servers
+			}
+		}
+		node = d.srvPicker(metrics, ctx.callInfo(), candidates)
+		if node == nil {
+			return nil, AllResourceDownError
+		}
+		if sticky {
+			d.sticky.bind(ctx.affinityKey, *node)
+		}
+	}
+	ctx.markTried(*node)
+	return node, nil
+}
+
+// CallInfo present read-only information of current `Do` call.
+// It's given to `ServerPicker` and `Filter` to help picking server.
+type CallInfo struct {
+	// Current present resource used by last attempt, it's nil when pick first server.
+	Current *Resource
+	// Tried present resources has been picked in current call.
+	Tried ResourceList
+	// AffinityKey present key given by `WithAffinityKey`.
+	AffinityKey string
+}
+
 // PickServer present pick server logic.
 // NewPick server logic must use this contract.
-type ServerPicker func(metrics *resourceMetrics, currentServer *Resource, servers ResourceList) *Resource
+//
+// Servers given to picker exclude resources tried in current call,
+// unless all of them has been tried and `ExhaustedPolicy` allows picking them again.
+type ServerPicker func(metrics Metrics, call *CallInfo, servers ResourceList) *Resource
 
 // RandomPick picks server using random index.
-func RandomPick(metrics *resourceMetrics, currentServer *Resource, servers ResourceList) *Resource {
+func RandomPick(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
 	if len(servers) == 0 {
 		return nil
 	}
-	currentIdx := servers.nodeIndex(currentServer)
+	currentIdx := servers.nodeIndex(call.Current)
 	if currentIdx == -1 {
 		return &servers[rand.Intn(len(servers))]
 	}
@@ -33,11 +100,11 @@ func RandomPick(metrics *resourceMetrics, currentServer *Resource, servers Resou
 
 // P2CPick picks server using P2C
 // https://www.eecs.harvard.edu/~michaelm/postscripts/tpds2001.pdf
-func P2CPick(metrics *resourceMetrics, currentServer *Resource, allNodes ResourceList) *Resource {
-	nodes := excludeCurrent(currentServer, allNodes)
+func P2CPick(metrics Metrics, call *CallInfo, allNodes ResourceList) *Resource {
+	nodes := excludeCurrent(call.Current, allNodes)
 	serverLen := len(nodes)
 	if serverLen == 0 {
-		return currentServer
+		return call.Current
 	}
 	if serverLen == 1 {
 		return &nodes[0]
@@ -50,12 +117,77 @@ func P2CPick(metrics *resourceMetrics, currentServer *Resource, allNodes Resourc
 	si2 := (si1 + delta) % serverLen
 	s1 := nodes[si1]
 	s2 := nodes[si2]
-	if metrics.takeMetric(s1).takeActiveReqCount() > metrics.takeMetric(s2).takeActiveReqCount() {
+	if metrics.ActiveRequests(s1) > metrics.ActiveRequests(s2) {
 		return &s2
 	}
 	return &s1
 }
 
+// NewRoundRobinPick returns ServerPicker which picks server one by one.
+func NewRoundRobinPick() ServerPicker {
+	var next uint64
+	return func(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
+		if len(servers) == 0 {
+			return nil
+		}
+		idx := atomic.AddUint64(&next, 1) - 1
+		return &servers[idx%uint64(len(servers))]
+	}
+}
+
+// HashPick picks server by hashing `AffinityKey` using rendezvous hashing,
+// so a key only moves when its server is gone.
+// It picks server randomly when call has no affinity key.
+func HashPick(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
+	if len(servers) == 0 {
+		return nil
+	}
+	if call.AffinityKey == "" {
+		return &servers[rand.Intn(len(servers))]
+	}
+	var picked *Resource
+	var maxWeight uint64
+	for i := range servers {
+		h := fnv.New64a()
+		h.Write([]byte(call.AffinityKey))
+		h.Write([]byte(servers[i].Server))
+		if weight := h.Sum64(); picked == nil || weight > maxWeight {
+			picked = &servers[i]
+			maxWeight = weight
+		}
+	}
+	return picked
+}
+
+func excludeTried(tried ResourceList, nodes ResourceList) ResourceList {
+	if len(tried) == 0 {
+		return nodes
+	}
+	untried := make(ResourceList, 0, len(nodes))
+	for _, node := range nodes {
+		if !tried.contains(node) {
+			untried = append(untried, node)
+		}
+	}
+	return untried
+}
+
+func leastFailed(metrics Metrics, nodes ResourceList) ResourceList {
+	var least ResourceList
+	var minFailures uint64
+	for _, node := range nodes {
+		failures := metrics.SuccessiveFailures(node)
+		switch {
+		case least == nil || failures < minFailures:
+			least = ResourceList{node}
+			minFailures = failures
+		case failures == minFailures:
+			least = append(least, node)
+		}
+	}
+	return least
+}
+
 func excludeCurrent(current *Resource, nodes ResourceList) ResourceList {
 	if current == nil {
 		return nodes
@@ -66,7 +198,7 @@ func excludeCurrent(current *Resource, nodes ResourceList) ResourceList {
 	}
 	excludedNodes := make(ResourceList, 0, size)
 	for _, node := range nodes {
-		if *current != node {
+		if current.Server != node.Server {
 			excludedNodes = append(excludedNodes, node)
 		}
 	}
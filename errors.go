@@ -0,0 +1,125 @@
+package faildep
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Attempt present one attempt executed by `Do`.
+type Attempt struct {
+	// Resource present resource the attempt executed on.
+	Resource Resource
+	// ServerAttempt present index of server pick in the call, start from 1.
+	ServerAttempt uint
+	// Attempt present index of attempt on the resource, start from 1.
+	Attempt uint
+	// Duration present how long the attempt took.
+	Duration time.Duration
+	// RepType present how the attempt is classified.
+	RepType RepType
+	// Err present error returned by the attempt.
+	Err error
+}
+
+// ExhaustedError returns when `Do` gives up without success,
+// it records every attempt and the reason why `Do` gives up.
+//
+// It matches its reason and every attempt error by `errors.Is` and `errors.As`,
+// e.g. `errors.Is(err, MaxRetryError)`.
+type ExhaustedError struct {
+	// Reason present why `Do` gives up, e.g. `MaxRetryError`, `AllResourceDownError`, or `context.DeadlineExceeded` when call timeout.
+	Reason error
+	// Attempts present attempts in execution order.
+	Attempts []Attempt
+}
+
+func (e *ExhaustedError) Error() string {
+	if len(e.Attempts) == 0 {
+		return e.Reason.Error()
+	}
+	last := e.Attempts[len(e.Attempts)-1]
+	return fmt.Sprintf("%v after %d attempts, last at %s: %v", e.Reason, len(e.Attempts), last.Resource.Server, last.Err)
+}
+
+// Unwrap returns reason and errors of every attempt.
+func (e *ExhaustedError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Attempts)+1)
+	errs = append(errs, e.Reason)
+	for _, a := range e.Attempts {
+		if a.Err != nil {
+			errs = append(errs, a.Err)
+		}
+	}
+	return errs
+}
+
+// Exclusion present why a resource can't be picked.
+type Exclusion struct {
+	// Resource present excluded resource.
+	Resource Resource
+	// Reason present why resource is excluded,
+	// e.g. `BreakerOpenError`, `BulkheadFullError`, `RateLimitedError`, `ClientThrottledError`.
+	Reason error
+}
+
+// RejectedError returns when no resource can be picked, it tells why every resource is excluded.
+//
+// It matches `AllResourceDownError` and its reason by `errors.Is`,
+// reason is `NoResourceError` when provider gives nothing, the common reason when all resources are excluded for same reason,
+// and `AllResourceDownError` when they are excluded for different reasons.
+// Resources excluded by `TrafficGroupError` are listed in exclusions but don't decide reason,
+// unless no resource is excluded for other reason.
+type RejectedError struct {
+	// Reason present why no resource can be picked.
+	Reason error
+	// Exclusions present why every resource is excluded.
+	Exclusions []Exclusion
+}
+
+func newRejectedError(exclusions []Exclusion) *RejectedError {
+	if len(exclusions) == 0 {
+		return &RejectedError{Reason: NoResourceError}
+	}
+	// resources outside traffic group of call aren't down, reason is decided by other resources.
+	var reason error
+	for _, e := range exclusions {
+		if e.Reason == TrafficGroupError {
+			continue
+		}
+		if reason == nil {
+			reason = e.Reason
+		} else if e.Reason != reason {
+			reason = AllResourceDownError
+			break
+		}
+	}
+	if reason == nil {
+		reason = TrafficGroupError
+	}
+	return &RejectedError{
+		Reason:     reason,
+		Exclusions: exclusions,
+	}
+}
+
+func (e *RejectedError) Error() string {
+	if len(e.Exclusions) == 0 {
+		return e.Reason.Error()
+	}
+	details := make([]string, 0, len(e.Exclusions))
+	for _, ex := range e.Exclusions {
+		details = append(details, fmt.Sprintf("%s: %v", ex.Resource.Server, ex.Reason))
+	}
+	return fmt.Sprintf("%v (%s)", e.Reason, strings.Join(details, ", "))
+}
+
+// Is reports whether target is `AllResourceDownError`, which is matched by every rejection.
+func (e *RejectedError) Is(target error) bool {
+	return target == AllResourceDownError
+}
+
+// Unwrap returns reason.
+func (e *RejectedError) Unwrap() error {
+	return e.Reason
+}
@@ -0,0 +1,122 @@
+package faildep
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestExhaustedError_attempts(t *testing.T) {
+	f := NewFailDepStatic("testExhausted", []string{"1", "2"},
+		WithRetry(1, 1, 0, 0, NoBackoff),
+	)
+	err := f.Do(func(node *Resource) error {
+		return testNetError{}
+	})
+	assert.True(t, errors.Is(err, MaxRetryError))
+	assert.False(t, errors.Is(err, AllResourceDownError))
+
+	var netErr testNetError
+	assert.True(t, errors.As(err, &netErr))
+
+	var exhausted *ExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Len(t, exhausted.Attempts, 4)
+	assert.Equal(t, uint(1), exhausted.Attempts[0].ServerAttempt)
+	assert.Equal(t, uint(2), exhausted.Attempts[1].Attempt)
+	assert.Equal(t, uint(2), exhausted.Attempts[3].ServerAttempt)
+	assert.NotEqual(t, exhausted.Attempts[0].Resource.Server, exhausted.Attempts[2].Resource.Server)
+	for _, a := range exhausted.Attempts {
+		assert.Equal(t, Fail|Retriable|Breakable, a.RepType)
+		assert.Equal(t, testNetError{}, a.Err)
+	}
+}
+
+func TestExhaustedError_noAttempt(t *testing.T) {
+	f := NewFailDepStatic("testExhaustedNoAttempt", []string{})
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, AllResourceDownError))
+	assert.True(t, errors.Is(err, NoResourceError))
+}
+
+func TestRejectedError_reasons(t *testing.T) {
+	f := NewFailDepStatic("testRejectedReasons", []string{"1", "2"},
+		WithCircuitBreaker(1, 10*time.Second, 10*time.Second, Exponential),
+		WithBulkhead(1, 10*time.Second),
+	)
+	for !f.metrics.Tripped(Resource{Server: "1"}) {
+		_ = f.Do(func(node *Resource) error {
+			if node.Server == "1" {
+				return testNetError{}
+			}
+			return nil
+		})
+	}
+	err := f.Do(func(node *Resource) error {
+		done := make(chan error)
+		go func() {
+			done <- f.Do(func(node *Resource) error {
+				return nil
+			})
+		}()
+		e := <-done
+		assert.True(t, errors.Is(e, AllResourceDownError))
+		var rejected *RejectedError
+		assert.True(t, errors.As(e, &rejected))
+		assert.Equal(t, AllResourceDownError, rejected.Reason)
+		assert.Len(t, rejected.Exclusions, 2)
+		assert.Equal(t, BreakerOpenError, rejected.Exclusions[0].Reason)
+		assert.Equal(t, BulkheadFullError, rejected.Exclusions[1].Reason)
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func TestRejectedError_sameReason(t *testing.T) {
+	err := newRejectedError([]Exclusion{
+		{Resource: Resource{Server: "1"}, Reason: BulkheadFullError},
+		{Resource: Resource{Server: "2"}, Reason: BulkheadFullError},
+	})
+	assert.True(t, errors.Is(err, BulkheadFullError))
+	assert.True(t, errors.Is(err, AllResourceDownError))
+	assert.False(t, errors.Is(err, BreakerOpenError))
+	assert.EqualError(t, err, "Bulkhead Is Full (1: Bulkhead Is Full, 2: Bulkhead Is Full)")
+}
+
+func TestRejectedError_trafficGroup(t *testing.T) {
+	f := NewFailDepStaticResources("testRejectedGroup", ResourceList{
+		{Server: "shadow", Tags: []string{ShadowTag}},
+		{Server: "canary", Tags: []string{CanaryTag}},
+	}, WithShadow(0, 1, 0), WithCanary(0, 0.1, 10))
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.False(t, errors.Is(err, NoResourceError))
+	assert.True(t, errors.Is(err, TrafficGroupError))
+	var rejected *RejectedError
+	assert.True(t, errors.As(err, &rejected))
+	assert.Len(t, rejected.Exclusions, 2)
+}
+
+func TestRejectedError_shadowIgnoredInReason(t *testing.T) {
+	f := NewFailDepStaticResources("testRejectedShadow", ResourceList{
+		{Server: "p1"},
+		{Server: "s1", Tags: []string{ShadowTag}},
+	}, WithShadow(0, 1, 0), WithCircuitBreaker(1, time.Minute, time.Minute, Exponential))
+	f.Do(func(node *Resource) error {
+		return testNetError{}
+	})
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, BreakerOpenError), err)
+	var rejected *RejectedError
+	assert.True(t, errors.As(err, &rejected))
+	if rejected != nil {
+		assert.Equal(t, BreakerOpenError, rejected.Reason)
+		assert.Len(t, rejected.Exclusions, 2)
+	}
+}
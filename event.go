@@ -0,0 +1,72 @@
+package faildep
+
+import (
+	"time"
+)
+
+// EventType present type of event emitted by FailDep.
+type EventType int
+
+const (
+	// RetryBudgetExhausted emits when retry is given up because retry budget has been used up.
+	RetryBudgetExhausted EventType = iota + 1
+	// CanaryBackOff emits when canary percent backs off to 0 because canary fails too much.
+	CanaryBackOff
+	// LeaseExpired emits when in-flight request is reclaimed because it runs beyond lease timeout.
+	LeaseExpired
+	// PanicRecovered emits when panic in service function is recovered.
+	PanicRecovered
+)
+
+func (t EventType) String() string {
+	switch t {
+	case RetryBudgetExhausted:
+		return "RetryBudgetExhausted"
+	case CanaryBackOff:
+		return "CanaryBackOff"
+	case LeaseExpired:
+		return "LeaseExpired"
+	case PanicRecovered:
+		return "PanicRecovered"
+	}
+	return "Unknown"
+}
+
+// Event present something happened in FailDep.
+type Event struct {
+	// Type present event type.
+	Type EventType
+	// Name present name of FailDep.
+	Name string
+	// Resource present resource related to event, it's nil when event isn't about a resource.
+	Resource *Resource
+	// Err present error related to event.
+	Err error
+	// Time present when event happened.
+	Time time.Time
+}
+
+// WithEventListener configure listener which receives events emitted by FailDep.
+//
+// Default: events are only logged.
+//
+// - listener indicate function called synchronously for every event, it should not block.
+func WithEventListener(listener func(e Event)) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.eventListener = listener
+	}
+}
+
+func (f *FailDep) emit(typ EventType, node *Resource, err error) {
+	f.logger.Warning("res:", f.name, "event:", typ, "error:", err)
+	if f.eventListener == nil {
+		return
+	}
+	f.eventListener(Event{
+		Type:     typ,
+		Name:     f.name,
+		Resource: node,
+		Err:      err,
+		Time:     time.Now(),
+	})
+}
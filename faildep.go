@@ -3,16 +3,16 @@
 // - dispatch request to available resource in resource list
 // - circuitBreaker break request when successive error or high concurrent number
 // - retry in one resource or try to do it in other resources
+// - deterministic subsetting to only use a balanced part of large resource list
+// - classify HTTP response by status code and respect `Retry-After`
 package faildep
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/faildep/faildep-log"
-	"net"
-	"net/url"
 	"runtime/debug"
-	"strings"
 	"time"
 )
 
@@ -21,6 +21,14 @@ var (
 	AllResourceDownError = fmt.Errorf("All Resource Has Down")
 	// MaxRetryError returns when retry beyond given maxRetry time
 	MaxRetryError = fmt.Errorf("Max retry but still failure")
+	// NoResourceError returns when no resource is given by provider
+	NoResourceError = fmt.Errorf("No Resource Configured")
+	// BreakerOpenError returns when circuit breaker of resource is open
+	BreakerOpenError = fmt.Errorf("Circuit Breaker Open")
+	// ResourceThrottledError returns when resource asks to slow down by response classified as `Throttled`
+	ResourceThrottledError = fmt.Errorf("Resource Throttled")
+	// TrafficGroupError returns when resource isn't in traffic group of call, e.g. shadow resource or canary resource for baseline call
+	TrafficGroupError = fmt.Errorf("Resource Not In Traffic Group")
 )
 
 // RepType present response type.
@@ -36,8 +44,27 @@ const (
 	Breakable
 	// Retriable indicate can retry
 	Retriable
+	// Redirect indicate resource asks to redo request on another resource given by error, e.g. Redis `MOVED`,
+	// it isn't counted as failure of resource.
+	Redirect
+	// Throttled indicate resource asks to slow down, resource isn't picked for a while but circuit breaker isn't tripped,
+	// retriable request is redone on another resource.
+	Throttled
+	// SameNode indicate retriable request can only be retried on same resource, e.g. deadlock.
+	SameNode
+	// OtherNode indicate retriable request can only be retried on other resource, e.g. too many connections.
+	OtherNode
+	// Unsent indicate request is proven not processed by resource, e.g. connection refused,
+	// only such request can be retried for call marked non-idempotent by `WithIdempotent(false)`.
+	Unsent
 )
 
+// throttledBackOff indicate how long resource isn't picked after it asks to slow down without `RetryAfter`.
+const throttledBackOff = 1 * time.Second
+
+// retryBudgetBurst indicate maximum retries can be saved in retry budget.
+const retryBudgetBurst = 100
+
 type funcFlag int
 
 const (
@@ -49,17 +76,32 @@ const (
 // Faildep present failable resources.
 // Create Faildep use `NewFaildep`
 type FailDep struct {
-	name              string
-	funcFlags         funcFlag
-	distributor       dispatcher
-	metrics           resourceMetrics
-	maxRetry          uint
-	maxRePick         uint
-	repClassify       func(err error) RepType
-	retryBaseInterval time.Duration
-	retryMaxInterval  time.Duration
-	retryBackOff      BackOff
-	logger            log.Logger
+	name               string
+	funcFlags          funcFlag
+	distributor        dispatcher
+	metrics            resourceMetrics
+	maxRetry           uint
+	maxRePick          uint
+	repClassify        func(err error) RepType
+	retryBaseInterval  time.Duration
+	retryMaxInterval   time.Duration
+	retryBackOff       BackOff
+	canary             *canary
+	shadow             *shadow
+	hedge              *hedge
+	retryBudget        *budget
+	throttle           *throttle
+	bulkheads          *bulkheads
+	limits             *adaptiveLimits
+	rateLimit          rateLimit
+	attemptTimeout     time.Duration
+	callTimeout        time.Duration
+	recoverPanic       bool
+	resultClassify     ResultClassifier
+	concurrency        *semaphore
+	concurrencyMaxWait time.Duration
+	eventListener      func(e Event)
+	logger             log.Logger
 }
 
 // WithCircuitBreaker configure CircuitBreaker config.
@@ -85,7 +127,7 @@ func WithCircuitBreaker(successiveFailThreshold uint64, trippedBaseTime time.Dur
 // Default: Bulkhead is disabled, we must use this OptFunc to enable it.
 //
 // - activeReqThreshold indicate maxActiveReqThreshold for one node
-// - activeReqCountWindow indicate time window for calculate activeReqCount
+// - activeReqCountWindow indicate maximum time one request counted as active, it's used as lease timeout when `WithLeaseTimeout` isn't given
 func WithBulkhead(activeReqThreshold uint64, activeReqCountWindow time.Duration) func(f *FailDep) {
 	return func(f *FailDep) {
 		f.funcFlags |= bulkhead
@@ -94,6 +136,20 @@ func WithBulkhead(activeReqThreshold uint64, activeReqCountWindow time.Duration)
 	}
 }
 
+// WithLeaseTimeout configure hard cap of one in-flight request.
+//
+// Default: use activeReqCountWindow given by `WithBulkhead`, or never reclaim when bulkhead is disabled.
+//
+// Every attempt holds a lease counting active request and bulkhead permit of resource,
+// lease running beyond timeout, e.g. service function hangs, is reclaimed and reported as leaked with `LeaseExpired` event.
+//
+// - timeout indicate maximum time one request can hold lease.
+func WithLeaseTimeout(timeout time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.metrics.leaseTimeout = timeout
+	}
+}
+
 // WithRetry configure Retry config
 //
 // Default: Retry is disabled, we must use this OptFunc to enable it.
@@ -115,6 +171,20 @@ func WithRetry(maxServerPick, maxRetryPerServer uint, retryBaseInterval, retryMa
 	}
 }
 
+// WithRetryBudget configure retry budget config, the budget is shared by all calls of FailDep.
+//
+// Default: retry budget is disabled, every call can retry as `WithRetry` configured.
+//
+// Once budget is used up, `Do` returns last error without retry and emits `RetryBudgetExhausted` event.
+//
+// - ratio indicate maximum ratio of retries to calls, e.g. 0.1 means retries are at most 10% of calls.
+// - minPerSecond indicate retries always allowed per second, so low traffic can still retry.
+func WithRetryBudget(ratio float64, minPerSecond float64) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.retryBudget = newBudget(ratio, retryBudgetBurst, minPerSecond)
+	}
+}
+
 // WithResponseClassifier config response classification config.
 //
 // - classifier indicate which classifier use to classify response
@@ -126,8 +196,17 @@ func WithResponseClassifier(classifier func(_err error) RepType) func(f *FailDep
 	}
 }
 
+// WithExhaustedPolicy config what to do when every available resource has been tried in one call.
+// Default use `ExhaustedStartOver`.
+func WithExhaustedPolicy(policy ExhaustedPolicy) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.distributor.exhausted = policy
+	}
+}
+
 // WithPickServer config server pick logic.
 // Default use `P2CPick` to pick server.
+// Use `NewPipelinePick` to combine filters and pickers.
 func WithPickServer(sp ServerPicker) func(f *FailDep) {
 	return func(f *FailDep) {
 		f.distributor.srvPicker = sp
@@ -144,8 +223,25 @@ func NewFailDepStatic(name string, nodes []string, opts ...func(f *FailDep)) *Fa
 // the node array is provide using string, e.g. `10.10.10.10:9999`
 // It's will be tweaked use OptFunction like `WithRetry`, `WithCiruitBreake`, `WithBulkhead`
 func NewFailDep(name string, nodes NodeProvider, opts ...func(f *FailDep)) *FailDep {
+	return newFailDep(name, nodeToResource(nodes), opts...)
+}
+
+// NewFailDepStaticResources construct FailDep using given static resource list.
+func NewFailDepStaticResources(name string, resources ResourceList, opts ...func(f *FailDep)) *FailDep {
+	return NewFailDepWithResources(name, func() (func() ResourceList, chan struct{}) {
+		return func() ResourceList {
+			return resources
+		}, make(chan struct{})
+	}, opts...)
+}
 
-	servers := nodeToResource(nodes)
+// NewFailDepWithResources construct FailDep using given resource list,
+// resource can take `Tags` and `Attrs` which can be used by `Filter`.
+func NewFailDepWithResources(name string, resources ResourceProvider, opts ...func(f *FailDep)) *FailDep {
+	return newFailDep(name, indexResource(resources), opts...)
+}
+
+func newFailDep(name string, servers ResourceProvider, opts ...func(f *FailDep)) *FailDep {
 
 	m := newNodeMetric(servers)
 
@@ -161,6 +257,10 @@ func NewFailDep(name string, nodes NodeProvider, opts ...func(f *FailDep)) *Fail
 		logger:       &log.StdLogger{},
 	}
 
+	f.metrics.leaseExpired = func(node Resource, held time.Duration) {
+		f.emit(LeaseExpired, &node, fmt.Errorf("request held lease for %s", held))
+	}
+
 	for _, opt := range opts {
 		opt(f)
 	}
@@ -169,79 +269,367 @@ func NewFailDep(name string, nodes NodeProvider, opts ...func(f *FailDep)) *Fail
 }
 
 // Do execute function which will be triggered on some node to do something.
-func (f *FailDep) Do(service func(node *Resource) error) error {
+//
+// It returns `*ExhaustedError` with every attempt when it gives up, e.g. retry beyond maxRetry or no resource available,
+// use `errors.Is(err, MaxRetryError)` or `errors.Is(err, AllResourceDownError)` to check reason.
+//
+// - opts indicate options for this call, e.g. `WithAffinityKey`
+func (f *FailDep) Do(service func(node *Resource) error, opts ...CallOption) error {
+	return f.DoContext(context.Background(), func(_ context.Context, node *Resource) error {
+		return service(node)
+	}, opts...)
+}
 
-	execContext := &executionContext{}
+// DoContext execute function like `Do`, and function will be given a context derived from ctx.
+func (f *FailDep) DoContext(ctx context.Context, service func(ctx context.Context, node *Resource) error, opts ...CallOption) error {
+	_, err := f.DoResultContext(ctx, func(ctx context.Context, node *Resource) (interface{}, error) {
+		return nil, service(ctx, node)
+	}, opts...)
+	return err
+}
+
+// DoResult execute function like `Do`, and returns result of the last attempt,
+// result is classified together with error when `WithResultClassifier` is configured.
+//
+// Results of retried attempts are released, e.g. body of `*http.Response` is closed,
+// and result is nil when it returns `*ExhaustedError`.
+func (f *FailDep) DoResult(service func(node *Resource) (interface{}, error), opts ...CallOption) (interface{}, error) {
+	return f.DoResultContext(context.Background(), func(_ context.Context, node *Resource) (interface{}, error) {
+		return service(node)
+	}, opts...)
+}
+
+// DoResultContext execute function like `DoResult`, and function will be given a context derived from ctx.
+//
+// Context given to function isn't cancelled by `Do` before returned result is released,
+// e.g. body of `*http.Response` can still be read until it's closed.
+func (f *FailDep) DoResultContext(ctx context.Context, service func(ctx context.Context, node *Resource) (interface{}, error), opts ...CallOption) (result interface{}, err error) {
+
+	if f.throttle != nil && !f.throttle.perResource && !f.throttle.allow(nil) {
+		f.logger.Warning("res:", f.name, "error:", ClientThrottledError)
+		return nil, ClientThrottledError
+	}
+
+	if f.concurrency != nil {
+		if err := f.concurrency.acquire(ctx, f.concurrencyMaxWait); err != nil {
+			f.logger.Warning("res:", f.name, "error:", err)
+			return nil, err
+		}
+		defer f.concurrency.release()
+	}
+
+	if f.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.callTimeout)
+		defer func() {
+			bindCancel(result, cancel)
+		}()
+	}
 
+	execContext := newExecutionContext(ctx, opts)
+	if f.canary != nil {
+		execContext.canary = f.canary.sample()
+	}
+	if f.hedge != nil {
+		f.hedge.budget.deposit()
+	}
+	if f.retryBudget != nil {
+		f.retryBudget.deposit()
+	}
+	if f.shadow != nil && !execContext.nonIdempotent && f.shadow.sample() {
+		f.mirror(service)
+	}
+
+	startTime := time.Now()
+	result, err = f.do(execContext, service)
+
+	if f.canary != nil && f.canary.record(execContext.canary, time.Now().Sub(startTime), err) {
+		f.emit(CanaryBackOff, nil, err)
+	}
+	return result, err
+}
+
+func (f *FailDep) do(execContext *executionContext, service serviceFunc) (interface{}, error) {
+
+	var lastResult interface{}
+	var lastErr error
 	for execContext.serverAttemptCount <= f.maxRePick {
 
+		if err := execContext.ctx.Err(); err != nil {
+			f.logger.Error("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "error:", err)
+			discardResult(lastResult)
+			return nil, execContext.exhausted(err)
+		}
+		if execContext.serverAttemptCount > 0 && f.retryBudget != nil && !f.retryBudget.withdraw() {
+			f.emit(RetryBudgetExhausted, execContext.node, lastErr)
+			return lastResult, lastErr
+		}
 		execContext.incServerAttemptCount()
 
-		avSrv := f.metrics.availableServer(f.funcFlags)
+		avSrv, exclusions := f.availableServers(execContext)
+		if len(avSrv) == 0 {
+			err := newRejectedError(exclusions)
+			f.logger.Error("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "error:", err)
+			discardResult(lastResult)
+			return nil, execContext.exhausted(err)
+		}
+
+		node, err := f.distributor.pick(&f.metrics, execContext, avSrv)
+		if err != nil {
+			f.logger.Error("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "error:", err)
+			discardResult(lastResult)
+			return nil, execContext.exhausted(err)
+		}
+		execContext.node = node
+
+		var r attemptResult
+		if f.hedge != nil && !execContext.nonIdempotent {
+			r = f.hedgeServer(execContext, node, avSrv, service)
+		} else {
+			r = f.tryServer(execContext.ctx, execContext, node, service)
+		}
+		for redirects := 0; r.redirect != nil; redirects++ {
+			discardResult(r.result)
+			if redirects >= maxRedirects {
+				discardResult(lastResult)
+				return nil, execContext.exhausted(TooManyRedirectsError)
+			}
+			r = f.tryServer(execContext.ctx, execContext, r.redirect, service)
+		}
+		discardResult(lastResult)
+		if r.finish {
+			if r.err == nil {
+				f.logger.Error("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "error:", r.err)
+			}
+			return r.result, r.err
+		}
+		lastResult, lastErr = r.result, r.err
+	}
+
+	discardResult(lastResult)
+	return nil, execContext.exhausted(MaxRetryError)
+}
 
-		execContext.node = f.distributor.srvPicker(&f.metrics, execContext.node, avSrv)
-		if execContext.node == nil {
-			f.logger.Error("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "error:", "AllServerHasDown")
-			return AllResourceDownError
+// availableServers returns resources can be picked by current call,
+// and why other resources are excluded.
+func (f *FailDep) availableServers(execContext *executionContext) (ResourceList, []Exclusion) {
+	f.metrics.reclaimExpiredLeases()
+	servers := f.metrics.allServers()
+	available := make(ResourceList, 0, len(servers))
+	var exclusions []Exclusion
+	for _, node := range servers {
+		if f.shadow != nil && node.HasTag(ShadowTag) {
+			exclusions = append(exclusions, Exclusion{
+				Resource: node,
+				Reason:   TrafficGroupError,
+			})
+			continue
+		}
+		if reason := f.exclusionReason(node); reason != nil {
+			if reason == ClientThrottledError {
+				f.throttle.skipped(node)
+			}
+			exclusions = append(exclusions, Exclusion{
+				Resource: node,
+				Reason:   reason,
+			})
+			continue
+		}
+		available = append(available, node)
+	}
+	if f.canary != nil {
+		group := f.canary.servers(execContext, available)
+		for _, node := range available {
+			if !group.contains(node) {
+				exclusions = append(exclusions, Exclusion{
+					Resource: node,
+					Reason:   TrafficGroupError,
+				})
+			}
 		}
+		available = group
+	}
+	return available, exclusions
+}
+
+// exclusionReason returns why node can't be picked, it's nil when node is available.
+func (f *FailDep) exclusionReason(node Resource) error {
+	if reason := f.metrics.exclusionReason(node, f.funcFlags); reason != nil {
+		return reason
+	}
+	switch {
+	case f.bulkheads != nil && f.bulkheads.full(node):
+		return BulkheadFullError
+	case f.limits != nil && f.limits.reached(&f.metrics, node):
+		return BulkheadFullError
+	case f.rateLimit.enabled() && f.rateLimit.exhausted(node):
+		return RateLimitedError
+	case f.throttle != nil && f.throttle.reject(node):
+		return ClientThrottledError
+	}
+	return nil
+}
 
-		metric := f.metrics.takeMetric(*execContext.node)
-		finish, err := func() (finish bool, errorOut error) {
-			metric.incActive()
-			defer metric.descActive()
-			for execContext.attemptCount <= f.maxRetry {
-				execContext.incAttemptCount()
-				startTime := time.Now()
-				err := service(execContext.node)
-				if err != nil {
-					f.logger.Warning("res:", f.name, "s-attempt:", execContext.serverAttemptCount,
-						"at:", execContext.node.Server, "r-attempt:", execContext.attemptCount,
-						"error:", err,
-					)
-				}
-				repType := f.repClassify(err)
-				rt := time.Now().Sub(startTime)
-				f.logger.Info("res:", f.name, "used:", rt.Nanoseconds()/1000,
-					"at:", execContext.node.Server)
-				switch {
-				case repType&OK == OK:
-					metric.recordSuccess(rt)
-					finish = true
-					return
-				case repType&Breakable == Breakable:
-					metric.recordFailure(rt)
-				}
-
-				if f.funcFlags&retry != retry || repType&Retriable != Retriable {
-					finish = true
-					errorOut = err
-					return
-				}
-
-				backOffTime := f.retryBackOff(f.retryBaseInterval, f.retryMaxInterval, execContext.attemptCount)
-				if backOffTime > 0 {
-					time.Sleep(backOffTime)
-				}
+// tryServer executes service on given node and retries on it when error is retriable,
+// it returns finish is false with last result and error when retry beyond maxRetry or response asks for other node,
+// and should pick other server.
+func (f *FailDep) tryServer(ctx context.Context, execContext *executionContext, node *Resource, service serviceFunc) (r attemptResult) {
+	metric := f.metrics.attemptMetric(*node)
+	sameNode := false
+	for attemptCount := uint(1); attemptCount <= f.maxRetry+1; attemptCount++ {
+		if err := ctx.Err(); err != nil {
+			discardResult(r.result)
+			r.finish = true
+			r.result = nil
+			r.err = execContext.exhausted(err)
+			return
+		}
+		l, repick, err := f.admit(ctx, metric, node)
+		if err != nil {
+			f.logger.Warning("res:", f.name, "s-attempt:", execContext.serverAttemptCount,
+				"at:", node.Server, "r-attempt:", attemptCount, "error:", err,
+			)
+			execContext.recordAttempt(Attempt{
+				Resource:      *node,
+				ServerAttempt: execContext.serverAttemptCount,
+				Attempt:       attemptCount,
+				RepType:       Fail | Unsent,
+				Err:           err,
+			})
+			discardResult(r.result)
+			r.finish = !repick || f.funcFlags&retry != retry
+			r.result = nil
+			r.err = err
+			return
+		}
+		discardResult(r.result)
+		inflight := metric.takeActiveReqCount()
+		startTime := time.Now()
+		r.result, err = f.runAttempt(ctx, l, node, service)
+		if err != nil {
+			f.logger.Warning("res:", f.name, "s-attempt:", execContext.serverAttemptCount,
+				"at:", node.Server, "r-attempt:", attemptCount,
+				"error:", err,
+			)
+		}
+		classification := f.classify(r.result, err)
+		repType := classification.Type
+		if ctx.Err() != nil {
+			// context is done by caller, call timeout or hedging, it isn't failure of resource.
+			repType &^= Breakable
+		}
+		rt := time.Now().Sub(startTime)
+		f.logger.Info("res:", f.name, "used:", rt.Nanoseconds()/1000,
+			"at:", node.Server)
+		attemptErr := err
+		if attemptErr == nil && repType&OK != OK {
+			attemptErr = &FailedResultError{Result: r.result}
+		}
+		execContext.recordAttempt(Attempt{
+			Resource:      *node,
+			ServerAttempt: execContext.serverAttemptCount,
+			Attempt:       attemptCount,
+			Duration:      rt,
+			RepType:       repType,
+			Err:           attemptErr,
+		})
+		if f.throttle != nil && !(metric.detached && f.throttle.perResource) {
+			f.throttle.record(node, repType&Breakable != Breakable)
+		}
+		if f.limits != nil && !metric.detached {
+			f.limits.takeLimit(*node).Update(rt, inflight, repType&Breakable == Breakable)
+		}
+		if repType&Redirect == Redirect {
+			if target, ok := f.redirectTarget(err); ok {
+				f.logger.Info("res:", f.name, "s-attempt:", execContext.serverAttemptCount,
+					"at:", node.Server, "redirect to:", target.Server)
+				r.redirect = target
+				r.err = err
+				return
 			}
-			execContext.resetAttemptCount()
-			finish = false
+		}
+		switch {
+		case repType&OK == OK:
+			metric.recordSuccess(rt)
+			r.finish = true
+			r.err = nil
 			return
-		}()
-		if finish {
-			if err == nil {
-				f.logger.Error("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "error:", err)
+		case repType&Throttled == Throttled:
+			backOff := classification.RetryAfter
+			if backOff <= 0 {
+				backOff = throttledBackOff
+			}
+			metric.recordThrottled(backOff)
+		case repType&Breakable == Breakable:
+			metric.recordFailure(rt)
+		}
+
+		if f.funcFlags&retry != retry || repType&Retriable != Retriable || !execContext.canRetry(repType, classification.NonIdempotent) {
+			r.finish = true
+			r.err = err
+			return
+		}
+
+		r.err = err
+		sameNode = repType&SameNode == SameNode
+		if !sameNode && repType&(OtherNode|Throttled) != 0 {
+			r.finish = false
+			return
+		}
+		if attemptCount <= f.maxRetry && f.retryBudget != nil && !f.retryBudget.withdraw() {
+			f.emit(RetryBudgetExhausted, node, attemptErr)
+			r.finish = true
+			return
+		}
+
+		backOffTime := f.retryBackOff(f.retryBaseInterval, f.retryMaxInterval, attemptCount)
+		if classification.RetryAfter > backOffTime {
+			backOffTime = classification.RetryAfter
+		}
+		if backOffTime > 0 {
+			select {
+			case <-time.After(backOffTime):
+			case <-ctx.Done():
+				discardResult(r.result)
+				r.finish = true
+				r.result = nil
+				r.err = execContext.exhausted(ctx.Err())
+				return
 			}
-			return err
 		}
 	}
+	r.finish = sameNode
+	return
+}
 
-	return MaxRetryError
+// admit takes rate limit token and bulkhead permit for one attempt on node,
+// and returns lease which counts the attempt as active and releases permit when attempt finished.
+// It returns repick is true when attempt should be done on other node.
+func (f *FailDep) admit(ctx context.Context, metric *resourceMetric, node *Resource) (l *lease, repick bool, err error) {
+	if f.rateLimit.enabled() {
+		if repick, err = f.rateLimit.acquire(ctx, *node, !metric.detached); err != nil {
+			return
+		}
+	}
+	var releasePermit func()
+	if f.bulkheads != nil && !metric.detached {
+		sem := f.bulkheads.takeSemaphore(*node)
+		if err = sem.acquire(ctx, f.bulkheads.maxWait); err != nil {
+			repick = err == BulkheadFullError
+			return
+		}
+		releasePermit = sem.release
+	}
+	l = metric.acquireLease(releasePermit)
+	return
 }
 
 type stats struct {
 	Av        int    `json:"av"`
 	Srv       string `json:"srv"`
 	ActiveReq uint64 `json:"activeReq"`
+	LeakedReq uint64 `json:"leakedReq"`
+	Limit     uint64 `json:"limit,omitempty"`
 	FailCount uint64 `json:"failCount"`
 }
 
@@ -261,11 +649,17 @@ func (f *FailDep) logStats() {
 				!(f.funcFlags&bulkhead == bulkhead && metric.takeActiveReqCount() >= f.metrics.activeThreshold) {
 				av = 1
 			}
+			var limit uint64
+			if f.limits != nil {
+				limit = f.limits.takeLimit(node).Limit()
+			}
 			ss = append(ss, stats{
 				Av:        av,
 				Srv:       node.Server,
 				FailCount: metric.takeFailCount(),
 				ActiveReq: metric.takeActiveReqCount(),
+				LeakedReq: metric.takeLeakedReqCount(),
+				Limit:     limit,
 			})
 		}
 
@@ -282,39 +676,12 @@ func (f *FailDep) logStats() {
 
 // NetworkErrorClassification uses to classify network error into ok/failure/retriable/breakable
 // It's default Response classifier for FailDep.
+//
+// It matches wrapped error by `errors.Is` and `errors.As`:
+// `syscall.ECONNREFUSED`, `*net.DNSError` and dial `*net.OpError` are `Fail | Breakable | Retriable | Unsent`,
+// `syscall.ECONNRESET`, `net.ErrClosed`, `context.DeadlineExceeded`, `io.ErrUnexpectedEOF`
+// and timeout `net.Error` are `Fail | Breakable | Retriable`, other `net.Error` is `Fail | Breakable`.
+// Attempt ended by context of caller or call timeout isn't counted as failure of resource, even if it's `Breakable`.
 func NetworkErrorClassification(_err error) RepType {
-	var typ RepType
-	if _err == nil {
-		typ |= OK
-		return typ
-	}
-	typ |= Fail
-	switch t := _err.(type) {
-	case net.Error:
-		if t.Timeout() {
-			typ |= Retriable
-		}
-		if strings.Contains(_err.Error(), "connection refused") {
-			typ |= Retriable
-		}
-		typ |= Breakable
-		return typ
-	case *url.Error:
-		if nestErr, ok := t.Err.(net.Error); ok {
-			if nestErr.Timeout() {
-				typ |= Retriable
-			}
-			if strings.Contains(_err.Error(), "connection refused") {
-				typ |= Retriable
-			}
-			typ |= Breakable
-			return typ
-		}
-	}
-	if _err != nil && strings.Contains(_err.Error(), "use of closed network connection") {
-		typ |= Breakable
-		typ |= Retriable
-		return typ
-	}
-	return typ
+	return networkClassification(_err)
 }
@@ -1,6 +1,7 @@
 package faildep
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"sync/atomic"
 	"testing"
@@ -83,7 +84,8 @@ func TestDisableBreaker(t *testing.T) {
 	err = f2.Do(func(node *Resource) error {
 		return testNetError{}
 	})
-	assert.EqualError(t, err, "All Resource Has Down")
+	assert.True(t, errors.Is(err, AllResourceDownError))
+	assert.True(t, errors.Is(err, BreakerOpenError))
 
 }
 
@@ -100,7 +102,8 @@ func TestFailureAndRestart(t *testing.T) {
 	err := f.Do(func(node *Resource) error {
 		return nil
 	})
-	assert.EqualError(t, err, "All Resource Has Down")
+	assert.True(t, errors.Is(err, AllResourceDownError))
+	assert.True(t, errors.Is(err, BreakerOpenError))
 	time.Sleep(2 * time.Second)
 	err = f.Do(func(node *Resource) error {
 		return nil
@@ -128,7 +131,8 @@ func TestExponentialTrippedTime(t *testing.T) {
 	err = f.Do(func(node *Resource) error {
 		return testNetError{}
 	})
-	assert.EqualError(t, err, "All Resource Has Down")
+	assert.True(t, errors.Is(err, AllResourceDownError))
+	assert.True(t, errors.Is(err, BreakerOpenError))
 
 	time.Sleep(2 * time.Millisecond)
 	err = f.Do(func(node *Resource) error {
@@ -178,3 +182,48 @@ func TestExponentialMaxTime(t *testing.T) {
 	})
 	assert.NoError(t, err)
 }
+
+func TestRePick_triesEveryServer(t *testing.T) {
+	f := NewFailDepStatic("testRePick", []string{"1", "2", "3"},
+		WithRetry(5, 0, 0, 0, NoBackoff),
+	)
+	var tried []string
+	err := f.Do(func(node *Resource) error {
+		tried = append(tried, node.Server)
+		return testNetError{}
+	})
+	assert.Error(t, err)
+	assert.Len(t, tried, 6)
+	assert.Contains(t, tried[:3], "1")
+	assert.Contains(t, tried[:3], "2")
+	assert.Contains(t, tried[:3], "3")
+}
+
+func TestRePick_exhaustedPolicy(t *testing.T) {
+	f := NewFailDepStatic("testExhaustedFail", []string{"1", "2", "3"},
+		WithRetry(5, 0, 0, 0, NoBackoff),
+		WithExhaustedPolicy(ExhaustedFail),
+	)
+	var count int
+	err := f.Do(func(node *Resource) error {
+		count++
+		return testNetError{}
+	})
+	assert.True(t, errors.Is(err, MaxRetryError))
+	assert.Equal(t, 3, count)
+
+	f = NewFailDepStatic("testExhaustedLeast", []string{"1", "2", "3"},
+		WithRetry(5, 0, 0, 0, NoBackoff),
+		WithExhaustedPolicy(ExhaustedReuseLeastFailed),
+	)
+	f.metrics.takeMetric(Resource{Server: "1"}).recordFailure(0)
+	f.metrics.takeMetric(Resource{Server: "3"}).recordFailure(0)
+	var tried []string
+	err = f.Do(func(node *Resource) error {
+		tried = append(tried, node.Server)
+		return testNetError{}
+	})
+	assert.Error(t, err)
+	assert.Len(t, tried, 6)
+	assert.Equal(t, "2", tried[3])
+}
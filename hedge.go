@@ -0,0 +1,147 @@
+package faildep
+
+import (
+	"context"
+	"time"
+)
+
+// hedgeBudgetBurst indicate maximum hedges can be saved in budget.
+const hedgeBudgetBurst = 10
+
+// WithHedging configure hedged request config.
+// It should only be used for idempotent operations, e.g. read, call marked by `WithIdempotent(false)` is never hedged.
+//
+// Default: Hedging is disabled, we must use this OptFunc to enable it.
+//
+// When attempt on a resource hasn't finished after delay, a hedged attempt will be started on another resource,
+// the first success will be returned and others will be cancelled through context.
+//
+// - delay indicate how long to wait before hedging, it's used when percentile is 0 or resource has no latency sample.
+// - percentile indicate use resource's observed latency percentile as delay, in [0, 100], e.g. 95.
+// - budgetRatio indicate maximum ratio of hedged attempts to calls, e.g. 0.1 means at most 10% calls hedged.
+func WithHedging(delay time.Duration, percentile float64, budgetRatio float64) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.hedge = &hedge{
+			delay:      delay,
+			percentile: percentile,
+			budget:     newBudget(budgetRatio, hedgeBudgetBurst, 0),
+		}
+	}
+}
+
+type hedge struct {
+	delay      time.Duration
+	percentile float64
+	budget     *budget
+}
+
+func (h *hedge) takeDelay(metrics *resourceMetrics, node Resource) time.Duration {
+	if h.percentile > 0 {
+		if delay := metrics.LatencyPercentile(node, h.percentile); delay > 0 {
+			return delay
+		}
+	}
+	return h.delay
+}
+
+type attemptResult struct {
+	finish   bool
+	result   interface{}
+	err      error
+	redirect *Resource
+	panic    *PanicError
+	// hedge indicate index of hedged attempt, and cancel releases its context.
+	hedge  int
+	cancel context.CancelFunc
+}
+
+// hedgeServer executes service on given node like `tryServer`,
+// and starts a hedged attempt on another server when it's slow.
+func (f *FailDep) hedgeServer(execContext *executionContext, node *Resource, servers ResourceList, service serviceFunc) attemptResult {
+	// every attempt has its own context, so returned one stays alive until its result is released.
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}()
+	results := make(chan attemptResult, 2)
+	run := func(node *Resource) {
+		ctx, cancel := context.WithCancel(execContext.ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			defer recoverTo(results)
+			r := f.tryServer(ctx, execContext, node, service)
+			r.hedge = index
+			results <- r
+		}()
+	}
+	release := func(r *attemptResult) {
+		discardResult(r.result)
+		r.cancel()
+	}
+
+	run(node)
+	running := 1
+	timer := time.NewTimer(f.hedge.takeDelay(&f.metrics, *node))
+	defer timer.Stop()
+	var last *attemptResult
+	for running > 0 {
+		select {
+		case <-timer.C:
+			if !f.hedge.budget.withdraw() {
+				continue
+			}
+			hedgeNode, err := f.distributor.pick(&f.metrics, execContext, servers)
+			if err != nil || hedgeNode.Server == node.Server {
+				continue
+			}
+			f.logger.Info("res:", f.name, "s-attempt:", execContext.serverAttemptCount, "hedge at:", hedgeNode.Server)
+			run(hedgeNode)
+			running++
+		case r := <-results:
+			running--
+			if r.panic != nil {
+				discardResults(results, running)
+				if last != nil {
+					discardResult(last.result)
+				}
+				panic(r.panic)
+			}
+			r.cancel, cancels[r.hedge] = cancels[r.hedge], nil
+			if (r.finish && r.err == nil) || r.redirect != nil {
+				discardResults(results, running)
+				if last != nil {
+					release(last)
+				}
+				bindCancel(r.result, r.cancel)
+				return r
+			}
+			if last == nil || r.finish {
+				if last != nil {
+					release(last)
+				}
+				last = &r
+			} else {
+				release(&r)
+			}
+		}
+	}
+	bindCancel(last.result, last.cancel)
+	return *last
+}
+
+// discardResults releases results of attempts still running after hedging has finished.
+func discardResults(results chan attemptResult, running int) {
+	if running == 0 {
+		return
+	}
+	go func() {
+		for i := 0; i < running; i++ {
+			discardResult((<-results).result)
+		}
+	}()
+}
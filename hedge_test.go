@@ -0,0 +1,60 @@
+package faildep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedging_firstSuccessWins(t *testing.T) {
+	f := NewFailDepStatic("testHedge", []string{"slow", "fast"},
+		WithHedging(5*time.Millisecond, 0, 1),
+		WithPickServer(func(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
+			return &servers[0]
+		}),
+	)
+	for i := 0; i < 10; i++ {
+		f.hedge.budget.deposit()
+	}
+
+	var cancelled int64
+	var winner string
+	startTime := time.Now()
+	err := f.DoContext(context.Background(), func(ctx context.Context, node *Resource) error {
+		if node.Server == "slow" {
+			select {
+			case <-ctx.Done():
+				atomic.AddInt64(&cancelled, 1)
+				return ctx.Err()
+			case <-time.After(time.Second):
+				return nil
+			}
+		}
+		winner = node.Server
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "fast", winner)
+	assert.True(t, time.Now().Sub(startTime) < 500*time.Millisecond)
+
+	for i := 0; i < 100 && atomic.LoadInt64(&cancelled) == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int64(1), atomic.LoadInt64(&cancelled))
+}
+
+func TestHedging_budget(t *testing.T) {
+	f := NewFailDepStatic("testHedgeBudget", []string{"1", "2"},
+		WithHedging(time.Millisecond, 0, 0),
+	)
+	var count int64
+	err := f.Do(func(node *Resource) error {
+		atomic.AddInt64(&count, 1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&count))
+}
@@ -0,0 +1,93 @@
+package faildep
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPClassification classify `*http.Response` result given by `DoResult`, use it with `WithResultClassifier`.
+//
+// - error is classified by `NetworkErrorClassification`.
+// - 5xx is `Fail | Breakable`, and 502, 503, 504 are also `Retriable`.
+// - 429 is `Fail | Throttled | Retriable`, resource isn't picked for a while but circuit breaker isn't tripped.
+// - other status is `OK`, e.g. 404 is a valid response of healthy resource.
+// - `Retry-After` header in seconds or HTTP-date is used as minimum backOff before next retry.
+// - request with non-idempotent method, e.g. POST, is `NonIdempotent` and only retried when it's `Unsent`,
+// unless response is returned and its request is marked idempotent by `Idempotency-Key` or `X-Idempotency-Key` header.
+// Transport error, e.g. connection reset or timeout, carries only method of request in `*url.Error`, so header isn't checked.
+// Call marked by `WithIdempotent` takes precedence over method and header, e.g. POST call marked by `WithIdempotent(true)` is retried.
+func HTTPClassification(result interface{}, err error) Classification {
+	resp, _ := result.(*http.Response)
+	if err != nil {
+		c := Classification{Type: NetworkErrorClassification(err)}
+		if resp != nil && resp.Request != nil {
+			c.NonIdempotent = !idempotentRequest(resp.Request)
+		} else if urlErr, ok := err.(*url.Error); ok {
+			c.NonIdempotent = !idempotentMethod(urlErr.Op)
+		}
+		return c
+	}
+	if resp == nil {
+		return Classification{Type: OK}
+	}
+	var c Classification
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		c.Type = Fail | Throttled | Retriable
+	case resp.StatusCode >= 500:
+		c.Type = Fail | Breakable
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			c.Type |= Retriable
+		}
+	default:
+		return Classification{Type: OK}
+	}
+	c.NonIdempotent = resp.Request != nil && !idempotentRequest(resp.Request)
+	c.RetryAfter = retryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return c
+}
+
+// idempotentRequest reports whether req can be retried safely, like `http.Transport` does.
+func idempotentRequest(req *http.Request) bool {
+	if idempotentMethod(req.Method) {
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+func idempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses `Retry-After` header value, it's 0 when value is empty or invalid.
+func retryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package faildep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func httpResponse(method string, status int, header http.Header) *http.Response {
+	req, _ := http.NewRequest(method, "http://localhost", nil)
+	if header == nil {
+		header = http.Header{}
+	}
+	req.Header = header
+	return &http.Response{StatusCode: status, Header: header, Request: req}
+}
+
+func TestHTTPClassification(t *testing.T) {
+	assert.Equal(t, OK, HTTPClassification(httpResponse("GET", 200, nil), nil).Type)
+	assert.Equal(t, OK, HTTPClassification(httpResponse("GET", 404, nil), nil).Type)
+	assert.Equal(t, Fail|Breakable, HTTPClassification(httpResponse("GET", 500, nil), nil).Type)
+	assert.Equal(t, Fail|Breakable|Retriable, HTTPClassification(httpResponse("GET", 503, nil), nil).Type)
+	assert.Equal(t, Fail|Throttled|Retriable, HTTPClassification(httpResponse("GET", 429, nil), nil).Type)
+	assert.Equal(t, Classification{Type: Fail | Breakable | Retriable, NonIdempotent: true}, HTTPClassification(httpResponse("POST", 503, nil), nil))
+	assert.Equal(t, Classification{Type: Fail | Breakable | Retriable}, HTTPClassification(httpResponse("POST", 503, http.Header{"Idempotency-Key": {"1"}}), nil))
+	assert.Equal(t, 3*time.Second, HTTPClassification(httpResponse("GET", 429, http.Header{"Retry-After": {"3"}}), nil).RetryAfter)
+}
+
+func httpTransportError(method string, err error) error {
+	return &url.Error{Op: method[:1] + strings.ToLower(method[1:]), URL: "http://localhost", Err: err}
+}
+
+func TestHTTPClassification_transportError(t *testing.T) {
+	reset := &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	refused := &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	assert.Equal(t, Classification{Type: Fail | Breakable | Retriable}, HTTPClassification(nil, httpTransportError("GET", reset)))
+	assert.Equal(t, Classification{Type: Fail | Breakable | Retriable, NonIdempotent: true}, HTTPClassification(nil, httpTransportError("POST", reset)))
+	assert.Equal(t, Classification{Type: Fail | Breakable | Retriable, NonIdempotent: true}, HTTPClassification(nil, httpTransportError("POST", testNetError{})))
+	assert.Equal(t, Classification{Type: Fail | Breakable | Retriable | Unsent, NonIdempotent: true}, HTTPClassification(nil, httpTransportError("POST", refused)))
+}
+
+func TestDoResult_httpTransportError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		ioutil.ReadAll(r.Body)
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		// reset connection, so client gets ECONNRESET after request is sent.
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}))
+	defer server.Close()
+
+	f := NewFailDepStatic("testDoResultHTTPTransportError", []string{server.URL},
+		WithRetry(0, 1, 0, 0, NoBackoff),
+		WithResultClassifier(HTTPClassification),
+	)
+	post := func(node *Resource) (interface{}, error) {
+		req, _ := http.NewRequest("POST", node.Server, strings.NewReader("body"))
+		req.Header.Set("Idempotency-Key", "1")
+		return http.DefaultClient.Do(req)
+	}
+	_, err := f.DoResult(post)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = f.DoResult(post, WithIdempotent(true))
+	assert.Error(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestDoResult_httpIdempotentCall(t *testing.T) {
+	f := NewFailDepStatic("testDoResultHTTPIdempotentCall", []string{"1"},
+		WithRetry(0, 1, 0, 0, NoBackoff),
+		WithResultClassifier(HTTPClassification),
+	)
+	for _, c := range []struct {
+		method string
+		opts   []CallOption
+		calls  int
+	}{
+		{"POST", nil, 1},
+		{"POST", []CallOption{WithIdempotent(true)}, 2},
+		{"GET", nil, 2},
+		{"GET", []CallOption{WithIdempotent(false)}, 1},
+	} {
+		calls := 0
+		f.DoResult(func(node *Resource) (interface{}, error) {
+			calls++
+			return httpResponse(c.method, 503, nil), nil
+		}, c.opts...)
+		assert.Equal(t, c.calls, calls, c.method, len(c.opts))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Duration(0), retryAfter("", now))
+	assert.Equal(t, time.Duration(0), retryAfter("bad", now))
+	assert.Equal(t, 120*time.Second, retryAfter("120", now))
+	assert.Equal(t, 30*time.Second, retryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now))
+	assert.Equal(t, time.Duration(0), retryAfter(now.Add(-30*time.Second).Format(http.TimeFormat), now))
+}
+
+func TestDoResult_http(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	f := NewFailDepStatic("testDoResultHTTP", []string{server.URL},
+		WithRetry(0, 1, 0, 0, NoBackoff),
+		WithResultClassifier(HTTPClassification),
+	)
+	start := time.Now()
+	result, err := f.DoResult(func(node *Resource) (interface{}, error) {
+		return http.Get(node.Server)
+	})
+	assert.NoError(t, err)
+	resp := result.(*http.Response)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.True(t, time.Now().Sub(start) >= time.Second)
+}
+
+func TestDoResult_exhausted(t *testing.T) {
+	f := NewFailDepStatic("testDoResultExhausted", []string{"1"},
+		WithRetry(0, 1, 0, 0, NoBackoff),
+		WithResultClassifier(HTTPClassification),
+	)
+	result, err := f.DoResult(func(node *Resource) (interface{}, error) {
+		return httpResponse("GET", 503, nil), nil
+	})
+	assert.Nil(t, result)
+	assert.EqualError(t, err, "Max retry but still failure after 2 attempts, last at 1: Failed Result: HTTP 503")
+}
+
+func TestDoResult_readBody(t *testing.T) {
+	body := make([]byte, 1<<20)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	for name, opt := range map[string]func(f *FailDep){
+		"attemptTimeout": WithAttemptTimeout(5 * time.Second),
+		"callTimeout":    WithCallTimeout(5 * time.Second),
+		"hedging":        WithHedging(time.Second, 0, 0.1),
+	} {
+		f := NewFailDepStatic("testDoResultReadBody", []string{server.URL},
+			WithResultClassifier(HTTPClassification),
+			opt,
+		)
+		result, err := f.DoResultContext(context.Background(), func(ctx context.Context, node *Resource) (interface{}, error) {
+			req, _ := http.NewRequest("GET", node.Server, nil)
+			return http.DefaultClient.Do(req.WithContext(ctx))
+		})
+		assert.NoError(t, err, name)
+		resp := result.(*http.Response)
+		data, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err, name)
+		assert.Equal(t, len(body), len(data), name)
+		resp.Body.Close()
+	}
+}
+
+func TestDoResult_nilResponse(t *testing.T) {
+	f := NewFailDepStatic("testDoResultNilResponse", []string{"1"},
+		WithRetry(0, 1, 0, 0, NoBackoff),
+		WithAttemptTimeout(time.Second),
+		WithCallTimeout(time.Second),
+		WithResultClassifier(HTTPClassification),
+	)
+	calls := 0
+	result, err := f.DoResult(func(node *Resource) (interface{}, error) {
+		calls++
+		var resp *http.Response
+		return resp, testNetError{}
+	})
+	assert.Nil(t, result)
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+}
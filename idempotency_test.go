@@ -0,0 +1,98 @@
+package faildep
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotent_nonIdempotentNotRetried(t *testing.T) {
+	f := NewFailDepStatic("testNonIdempotent", []string{"1", "2"},
+		WithRetry(2, 2, 0, 0, NoBackoff),
+		WithResponseClassifier(outcomeClassifier(Fail|Breakable|Retriable)),
+	)
+	calls := 0
+	err := f.Do(func(node *Resource) error {
+		calls++
+		return errors.New("timeout")
+	}, WithIdempotent(false))
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+
+	calls = 0
+	err = f.Do(func(node *Resource) error {
+		calls++
+		return errors.New("timeout")
+	}, WithIdempotent(true))
+	assert.Error(t, err)
+	assert.Equal(t, 9, calls)
+}
+
+func TestIdempotent_unsentRetried(t *testing.T) {
+	f := NewFailDepStatic("testNonIdempotentUnsent", []string{"1", "2"},
+		WithRetry(2, 0, 0, 0, NoBackoff),
+		WithResponseClassifier(outcomeClassifier(Fail|Breakable|Retriable|Unsent)),
+	)
+	calls := 0
+	err := f.Do(func(node *Resource) error {
+		calls++
+		if calls == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}, WithIdempotent(false))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotent_attemptTimeoutNotRetried(t *testing.T) {
+	f := NewFailDepStatic("testNonIdempotentTimeout", []string{"1", "2"},
+		WithRetry(2, 2, 0, 0, NoBackoff),
+		WithAttemptTimeout(10*time.Millisecond),
+	)
+	var calls int32
+	err := f.Do(func(node *Resource) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, WithIdempotent(false))
+	assert.True(t, errors.Is(err, AttemptTimeoutError))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotent_noHedging(t *testing.T) {
+	f := NewFailDepStatic("testNonIdempotentHedge", []string{"1", "2"},
+		WithHedging(5*time.Millisecond, 0, 1),
+	)
+	var calls int32
+	err := f.Do(func(node *Resource) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}, WithIdempotent(false))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotent_noShadow(t *testing.T) {
+	f := NewFailDepStaticResources("testNonIdempotentShadow", ResourceList{
+		{Server: "primary"},
+		{Server: "shadow", Tags: []string{ShadowTag}},
+	}, WithShadow(1, 1, time.Second))
+	var mirrored int32
+	for i := 0; i < 5; i++ {
+		err := f.Do(func(node *Resource) error {
+			if node.Server == "shadow" {
+				atomic.AddInt32(&mirrored, 1)
+			}
+			return nil
+		}, WithIdempotent(false))
+		assert.NoError(t, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&mirrored))
+	stats, _ := f.ShadowStats()
+	assert.Equal(t, uint64(0), stats.Requests)
+}
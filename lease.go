@@ -0,0 +1,103 @@
+package faildep
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// lease present an in-flight request on resource,
+// it's released when request finished or reclaimed when it's running beyond lease timeout.
+type lease struct {
+	metric    *resourceMetric
+	id        uint64
+	start     time.Time
+	released  uint32
+	onRelease func()
+}
+
+// acquireLease counts a new in-flight request,
+// onRelease will be called once when lease is released or reclaimed, e.g. release bulkhead permit.
+func (n *resourceMetric) acquireLease(onRelease func()) *lease {
+	n.leaseLock.Lock()
+	n.nextLeaseID++
+	l := &lease{
+		metric:    n,
+		id:        n.nextLeaseID,
+		start:     time.Now(),
+		onRelease: onRelease,
+	}
+	n.leases[l.id] = l
+	atomic.AddUint64(&n.activeReqCount, 1)
+	n.leaseLock.Unlock()
+	return l
+}
+
+// release releases lease, it returns false when lease has been released or reclaimed.
+func (l *lease) release() bool {
+	if !atomic.CompareAndSwapUint32(&l.released, 0, 1) {
+		return false
+	}
+	n := l.metric
+	n.leaseLock.Lock()
+	delete(n.leases, l.id)
+	atomic.AddUint64(&n.activeReqCount, ^uint64(0))
+	n.leaseLock.Unlock()
+	if l.onRelease != nil {
+		l.onRelease()
+	}
+	return true
+}
+
+// reclaimExpiredLeases reclaims leases held beyond timeout and reports them as leaked.
+func (n *resourceMetric) reclaimExpiredLeases(timeout time.Duration) {
+	now := time.Now()
+	n.leaseLock.Lock()
+	expired := make([]*lease, 0)
+	for _, l := range n.leases {
+		if now.Sub(l.start) > timeout {
+			expired = append(expired, l)
+		}
+	}
+	n.leaseLock.Unlock()
+	for _, l := range expired {
+		if !l.release() {
+			continue
+		}
+		atomic.AddUint64(&n.leakedReqCount, 1)
+		if n.metrics.leaseExpired != nil {
+			n.metrics.leaseExpired(n.resource, now.Sub(l.start))
+		}
+	}
+}
+
+// takeLeaseTimeout returns hard cap of in-flight request,
+// `WithBulkhead`'s activeReqCountWindow is used when lease timeout isn't given.
+func (n *resourceMetrics) takeLeaseTimeout() time.Duration {
+	if n.leaseTimeout > 0 {
+		return n.leaseTimeout
+	}
+	return n.activeReqCountWindow
+}
+
+// reclaimExpiredLeases reclaims expired leases of all resources,
+// it's done at most once every half lease timeout.
+func (n *resourceMetrics) reclaimExpiredLeases() {
+	timeout := n.takeLeaseTimeout()
+	if timeout <= 0 {
+		return
+	}
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&n.lastLeaseSweep)
+	if now-last < int64(timeout/2) || !atomic.CompareAndSwapInt64(&n.lastLeaseSweep, last, now) {
+		return
+	}
+	n.metricsLock.RLock()
+	metrics := make([]*resourceMetric, 0, len(n.metrics))
+	for _, m := range n.metrics {
+		metrics = append(metrics, m)
+	}
+	n.metricsLock.RUnlock()
+	for _, m := range metrics {
+		m.reclaimExpiredLeases(timeout)
+	}
+}
@@ -0,0 +1,241 @@
+package faildep
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// LimitAlgorithm present algorithm which adjusts concurrency limit of one resource
+// from observed latency and drop signals.
+// It will be called concurrently.
+type LimitAlgorithm interface {
+	// Update adjusts limit with sample of a finished attempt and returns new limit.
+	//
+	// - rtt indicate response time of attempt.
+	// - inflight indicate in-flight requests on resource when attempt started.
+	// - dropped indicate attempt is dropped, e.g. timeout or overload.
+	Update(rtt time.Duration, inflight uint64, dropped bool) uint64
+	// Limit returns current limit.
+	Limit() uint64
+}
+
+// WithAdaptiveLimit configure adaptive concurrency limit config.
+//
+// Default: adaptive limit is disabled, we must use this OptFunc to enable it.
+//
+// Every resource has its own limit adjusted by algorithm, resource whose active requests reach limit will not be picked,
+// and call returns `BulkheadFullError` when every resource reaches its limit.
+//
+// - newLimit indicate how to create limit algorithm for one resource, e.g. `NewAIMDLimit`, `NewGradientLimit`, `NewVegasLimit`.
+func WithAdaptiveLimit(newLimit func() LimitAlgorithm) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.limits = &adaptiveLimits{
+			newLimit: newLimit,
+			limits:   make(map[string]LimitAlgorithm),
+		}
+	}
+}
+
+// ConcurrencyLimits returns current adaptive concurrency limit of resources, keyed by `Server`.
+func (f *FailDep) ConcurrencyLimits() map[string]uint64 {
+	if f.limits == nil {
+		return nil
+	}
+	return f.limits.snapshot()
+}
+
+type adaptiveLimits struct {
+	lock     sync.Mutex
+	newLimit func() LimitAlgorithm
+	limits   map[string]LimitAlgorithm
+}
+
+func (a *adaptiveLimits) takeLimit(node Resource) LimitAlgorithm {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	l, ok := a.limits[node.Server]
+	if !ok {
+		l = a.newLimit()
+		a.limits[node.Server] = l
+	}
+	return l
+}
+
+// reached returns whether active requests of resource reach its limit.
+func (a *adaptiveLimits) reached(metrics Metrics, node Resource) bool {
+	return metrics.ActiveRequests(node) >= a.takeLimit(node).Limit()
+}
+
+func (a *adaptiveLimits) snapshot() map[string]uint64 {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	limits := make(map[string]uint64, len(a.limits))
+	for server, l := range a.limits {
+		limits[server] = l.Limit()
+	}
+	return limits
+}
+
+func clampLimit(limit float64, min, max uint64) uint64 {
+	if limit < float64(min) {
+		return min
+	}
+	if limit > float64(max) {
+		return max
+	}
+	return uint64(limit)
+}
+
+// NewAIMDLimit returns additive-increase/multiplicative-decrease limit.
+// Limit increases by 1 when resource is well used, and multiplied by backOffRatio when attempt dropped or slower than timeout.
+//
+// - initial, min, max indicate initial, minimum and maximum limit.
+// - backOffRatio indicate how limit decreases, in (0, 1), e.g. 0.9.
+// - timeout indicate attempt slower than it is treated as dropped.
+func NewAIMDLimit(initial, min, max uint64, backOffRatio float64, timeout time.Duration) LimitAlgorithm {
+	return &aimdLimit{
+		limit:        initial,
+		min:          min,
+		max:          max,
+		backOffRatio: backOffRatio,
+		timeout:      timeout,
+	}
+}
+
+type aimdLimit struct {
+	lock         sync.Mutex
+	limit        uint64
+	min          uint64
+	max          uint64
+	backOffRatio float64
+	timeout      time.Duration
+}
+
+func (l *aimdLimit) Update(rtt time.Duration, inflight uint64, dropped bool) uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	switch {
+	case dropped || (l.timeout > 0 && rtt > l.timeout):
+		l.limit = clampLimit(float64(l.limit)*l.backOffRatio, l.min, l.max)
+	case inflight*2 >= l.limit:
+		l.limit = clampLimit(float64(l.limit+1), l.min, l.max)
+	}
+	return l.limit
+}
+
+func (l *aimdLimit) Limit() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.limit
+}
+
+// NewGradientLimit returns limit adjusted by gradient between min RTT and sampled RTT.
+// via: https://github.com/Netflix/concurrency-limits
+//
+// new limit = limit * clamp(minRTT / rtt, 0.5, 1) + sqrt(limit), and smoothed by smoothing.
+//
+// - initial, min, max indicate initial, minimum and maximum limit.
+// - smoothing indicate weight of new limit, in (0, 1], e.g. 0.2.
+func NewGradientLimit(initial, min, max uint64, smoothing float64) LimitAlgorithm {
+	return &gradientLimit{
+		limit:     float64(initial),
+		min:       min,
+		max:       max,
+		smoothing: smoothing,
+	}
+}
+
+type gradientLimit struct {
+	lock      sync.Mutex
+	limit     float64
+	min       uint64
+	max       uint64
+	smoothing float64
+	minRTT    time.Duration
+}
+
+func (l *gradientLimit) Update(rtt time.Duration, inflight uint64, dropped bool) uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if rtt <= 0 {
+		return clampLimit(l.limit, l.min, l.max)
+	}
+	if l.minRTT == 0 || rtt < l.minRTT {
+		l.minRTT = rtt
+	}
+	gradient := math.Max(0.5, math.Min(1, float64(l.minRTT)/float64(rtt)))
+	if dropped {
+		gradient = 0.5
+	}
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	if newLimit > l.limit && float64(inflight)*2 < l.limit {
+		newLimit = l.limit
+	}
+	l.limit = l.limit*(1-l.smoothing) + newLimit*l.smoothing
+	l.limit = float64(clampLimit(l.limit, l.min, l.max))
+	return uint64(l.limit)
+}
+
+func (l *gradientLimit) Limit() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return uint64(l.limit)
+}
+
+// NewVegasLimit returns limit estimating queue size of resource like TCP Vegas.
+// via: https://github.com/Netflix/concurrency-limits
+//
+// queue = limit * (1 - minRTT / rtt), limit increases when queue is small and decreases when queue is large or attempt dropped.
+//
+// - initial, min, max indicate initial, minimum and maximum limit.
+func NewVegasLimit(initial, min, max uint64) LimitAlgorithm {
+	return &vegasLimit{
+		limit: float64(initial),
+		min:   min,
+		max:   max,
+	}
+}
+
+type vegasLimit struct {
+	lock   sync.Mutex
+	limit  float64
+	min    uint64
+	max    uint64
+	minRTT time.Duration
+}
+
+func (l *vegasLimit) Update(rtt time.Duration, inflight uint64, dropped bool) uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if rtt <= 0 {
+		return clampLimit(l.limit, l.min, l.max)
+	}
+	if l.minRTT == 0 || rtt < l.minRTT {
+		l.minRTT = rtt
+	}
+	step := math.Max(1, math.Log10(l.limit))
+	alpha := 3 * step
+	beta := 6 * step
+	queue := math.Ceil(l.limit * (1 - float64(l.minRTT)/float64(rtt)))
+	switch {
+	case dropped:
+		l.limit -= step
+	case float64(inflight)*2 < l.limit:
+		// resource isn't well used, keep limit.
+	case queue <= step:
+		l.limit += beta
+	case queue < alpha:
+		l.limit += step
+	case queue > beta:
+		l.limit -= step
+	}
+	l.limit = float64(clampLimit(l.limit, l.min, l.max))
+	return uint64(l.limit)
+}
+
+func (l *vegasLimit) Limit() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return uint64(l.limit)
+}
@@ -0,0 +1,76 @@
+package faildep
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAIMDLimit(t *testing.T) {
+	l := NewAIMDLimit(10, 2, 12, 0.5, 100*time.Millisecond)
+	assert.Equal(t, uint64(10), l.Update(time.Millisecond, 1, false))
+	assert.Equal(t, uint64(11), l.Update(time.Millisecond, 6, false))
+	assert.Equal(t, uint64(12), l.Update(time.Millisecond, 6, false))
+	assert.Equal(t, uint64(12), l.Update(time.Millisecond, 12, false))
+	assert.Equal(t, uint64(6), l.Update(time.Millisecond, 12, true))
+	assert.Equal(t, uint64(3), l.Update(time.Second, 12, false))
+	assert.Equal(t, uint64(2), l.Update(time.Second, 12, false))
+}
+
+func TestGradientLimit(t *testing.T) {
+	l := NewGradientLimit(20, 1, 100, 1)
+	for i := 0; i < 10; i++ {
+		l.Update(10*time.Millisecond, 20, false)
+	}
+	grown := l.Limit()
+	assert.True(t, grown > 20, grown)
+	for i := 0; i < 10; i++ {
+		l.Update(100*time.Millisecond, grown, false)
+	}
+	assert.True(t, l.Limit() < grown, l.Limit())
+}
+
+func TestVegasLimit(t *testing.T) {
+	l := NewVegasLimit(10, 1, 100)
+	l.Update(10*time.Millisecond, 10, false)
+	assert.True(t, l.Limit() > 10, l.Limit())
+	grown := l.Limit()
+	for i := 0; i < 5; i++ {
+		l.Update(50*time.Millisecond, grown, false)
+	}
+	assert.True(t, l.Limit() < grown, l.Limit())
+	shrunk := l.Limit()
+	l.Update(10*time.Millisecond, shrunk, true)
+	assert.True(t, l.Limit() < shrunk, l.Limit())
+}
+
+func TestAdaptiveLimit_withFailDep(t *testing.T) {
+	f := NewFailDepStatic("testAdaptiveLimit", []string{"1", "2"},
+		WithAdaptiveLimit(func() LimitAlgorithm {
+			return NewAIMDLimit(4, 1, 10, 0.5, time.Second)
+		}),
+		WithPickServer(func(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
+			return &servers[0]
+		}),
+	)
+	for i := 0; i < 4; i++ {
+		f.Do(func(node *Resource) error {
+			assert.Equal(t, "1", node.Server)
+			return testNetError{}
+		})
+	}
+	limits := f.ConcurrencyLimits()
+	limit, ok := limits["1"]
+	assert.True(t, ok, limits)
+	assert.True(t, limit < 4, limits)
+
+	f.metrics.takeMetric(Resource{Server: "1"}).acquireLease(nil)
+	f.metrics.takeMetric(Resource{Server: "2"}).acquireLease(nil)
+	f.limits.limits["1"] = NewAIMDLimit(1, 1, 1, 0.5, time.Second)
+	f.limits.limits["2"] = NewAIMDLimit(1, 1, 1, 0.5, time.Second)
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, BulkheadFullError))
+}
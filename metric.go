@@ -1,12 +1,36 @@
 package faildep
 
 import (
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 )
 
+// Metrics present read-only view of resource metrics.
+// It's given to `ServerPicker` and `Filter` to help picking server.
+type Metrics interface {
+	// ActiveRequests returns active request count on resource.
+	ActiveRequests(res Resource) uint64
+	// SuccessiveFailures returns successive failure count of resource.
+	SuccessiveFailures(res Resource) uint64
+	// Tripped returns whether circuit breaker of resource is open.
+	Tripped(res Resource) bool
+	// Latency returns smoothed response time of resource, it's 0 before first response.
+	Latency(res Resource) time.Duration
+	// LatencyPercentile returns given percentile of recent response time, it's 0 before first response.
+	// e.g. 99 returns p99 latency.
+	LatencyPercentile(res Resource, percentile float64) time.Duration
+}
+
+const (
+	// latencyDecay indicate weight of new sample in smoothed latency.
+	latencyDecay = 0.3
+	// latencySampleSize indicate how many recent response time kept for percentile.
+	latencySampleSize = 128
+)
+
 type opType int
 
 type op struct {
@@ -17,12 +41,15 @@ type resourceMetrics struct {
 	metricsLock          sync.RWMutex
 	resources            func() ResourceList
 	resChangeChan        chan struct{}
-	metrics              map[Resource]*resourceMetric
+	metrics              map[string]*resourceMetric
 	failureThreshold     uint64
 	activeThreshold      uint64
 	trippedBaseTime      time.Duration
 	trippedTimeoutMax    time.Duration
 	activeReqCountWindow time.Duration
+	leaseTimeout         time.Duration
+	lastLeaseSweep       int64
+	leaseExpired         func(node Resource, held time.Duration)
 	trippedBackOff       BackOff
 }
 
@@ -32,7 +59,7 @@ func newNodeMetric(resources ResourceProvider) *resourceMetrics {
 	nm := &resourceMetrics{
 		resources:      res,
 		resChangeChan:  c,
-		metrics:        make(map[Resource]*resourceMetric, initSize),
+		metrics:        make(map[string]*resourceMetric, initSize),
 		trippedBackOff: Exponential,
 	}
 	return nm
@@ -43,34 +70,100 @@ func (n *resourceMetrics) allServers() ResourceList {
 }
 
 func (n *resourceMetrics) availableServer(funcFlags funcFlag) ResourceList {
+	n.reclaimExpiredLeases()
 	servers := n.resources()
 	nodes := make([]Resource, 0, len(servers))
 	for _, node := range servers {
-		m := n.takeMetric(node)
-		activeReqCount := atomic.LoadUint64(&m.activeReqCount)
-		if !(funcFlags&circuitBreaker == circuitBreaker && m.isCircuitBreakTripped()) &&
-			!(funcFlags&bulkhead == bulkhead && activeReqCount >= n.activeThreshold) {
+		if n.exclusionReason(node, funcFlags) == nil {
 			nodes = append(nodes, node)
 		}
 	}
 	return nodes
 }
 
+// exclusionReason returns why node can't be picked, it's nil when node is available.
+func (n *resourceMetrics) exclusionReason(node Resource, funcFlags funcFlag) error {
+	m := n.takeMetric(node)
+	if funcFlags&circuitBreaker == circuitBreaker && m.isCircuitBreakTripped() {
+		return BreakerOpenError
+	}
+	if funcFlags&bulkhead == bulkhead && m.takeActiveReqCount() >= n.activeThreshold {
+		return BulkheadFullError
+	}
+	if m.isThrottled() {
+		return ResourceThrottledError
+	}
+	return nil
+}
+
+// takeMetric returns metric of given resource,
+// metric is keyed by `Server` so it survives index change when resource list changes.
 func (n *resourceMetrics) takeMetric(nd Resource) *resourceMetric {
 	n.metricsLock.Lock()
-	m, ok := n.metrics[nd]
+	m, ok := n.metrics[nd.Server]
 	if !ok {
-		m = &resourceMetric{
-			metrics:             n,
-			successiveFailCount: 0,
-			activeReqCount:      0,
-		}
-		n.metrics[nd] = m
+		m = n.newMetric(nd)
+		n.metrics[nd.Server] = m
 	}
 	n.metricsLock.Unlock()
 	return m
 }
 
+// attemptMetric returns metric of resource to attempt,
+// resource isn't in resource list, e.g. target of redirect, is given a detached metric which isn't kept,
+// so its breaker, lease and latency don't outlive the attempt.
+func (n *resourceMetrics) attemptMetric(nd Resource) *resourceMetric {
+	n.metricsLock.RLock()
+	m, ok := n.metrics[nd.Server]
+	n.metricsLock.RUnlock()
+	if ok {
+		return m
+	}
+	for _, res := range n.allServers() {
+		if res.Server == nd.Server {
+			return n.takeMetric(nd)
+		}
+	}
+	m = n.newMetric(nd)
+	m.detached = true
+	return m
+}
+
+func (n *resourceMetrics) newMetric(nd Resource) *resourceMetric {
+	return &resourceMetric{
+		metrics:             n,
+		resource:            nd,
+		successiveFailCount: 0,
+		activeReqCount:      0,
+		leases:              make(map[uint64]*lease),
+	}
+}
+
+// ActiveRequests implements Metrics.
+func (n *resourceMetrics) ActiveRequests(res Resource) uint64 {
+	return n.takeMetric(res).takeActiveReqCount()
+}
+
+// SuccessiveFailures implements Metrics.
+func (n *resourceMetrics) SuccessiveFailures(res Resource) uint64 {
+	return n.takeMetric(res).takeFailCount()
+}
+
+// Tripped implements Metrics.
+func (n *resourceMetrics) Tripped(res Resource) bool {
+	return n.takeMetric(res).isCircuitBreakTripped()
+}
+
+// Latency implements Metrics.
+func (n *resourceMetrics) Latency(res Resource) time.Duration {
+	return n.takeMetric(res).takeLatency()
+}
+
+// LatencyPercentile implements Metrics.
+func (n *resourceMetrics) LatencyPercentile(res Resource, percentile float64) time.Duration {
+	return n.takeMetric(res).takeLatencyPercentile(percentile)
+}
+
 func (n *resourceMetrics) takeCircuitBreakerBlackoutPeriod(successiveFailCount uint64) time.Duration {
 	if successiveFailCount < n.failureThreshold {
 		return 0 * time.Second
@@ -83,48 +176,95 @@ func (n *resourceMetrics) takeCircuitBreakerBlackoutPeriod(successiveFailCount u
 }
 
 type resourceMetric struct {
-	metrics                      *resourceMetrics
-	successiveFailCount          uint64
-	activeReqCount               uint64
-	lastFailedTimestamp          unsafe.Pointer
-	lastActiveReqCountChangeTime unsafe.Pointer
+	metrics             *resourceMetrics
+	resource            Resource
+	successiveFailCount uint64
+	activeReqCount      uint64
+	leakedReqCount      uint64
+	latency             int64
+	latencySamples      [latencySampleSize]int64
+	latencySampleCount  uint64
+	lastFailedTimestamp unsafe.Pointer
+	throttledUntil      int64
+	leaseLock           sync.Mutex
+	leases              map[uint64]*lease
+	nextLeaseID         uint64
+	// detached indicate resource isn't in resource list, per-resource limits aren't applied to it.
+	detached bool
 }
 
 func (n *resourceMetric) recordSuccess(rt time.Duration) {
 	atomic.StoreUint64(&n.successiveFailCount, 0)
+	n.recordLatency(rt)
 }
 
 func (n *resourceMetric) recordFailure(rt time.Duration) {
 	current := time.Now()
+	n.recordLatency(rt)
 	atomic.AddUint64(&n.successiveFailCount, 1)
 	atomic.StorePointer(&n.lastFailedTimestamp, unsafe.Pointer(&current))
 	return
 }
 
-func (n *resourceMetric) incActive() {
-	current := time.Now()
-	atomic.AddUint64(&n.activeReqCount, 1)
-	atomic.StorePointer(&n.lastActiveReqCountChangeTime, unsafe.Pointer(&current))
+// recordThrottled records resource asks to slow down, resource isn't available in backOff.
+func (n *resourceMetric) recordThrottled(backOff time.Duration) {
+	atomic.StoreInt64(&n.throttledUntil, time.Now().Add(backOff).UnixNano())
 }
 
-func (n *resourceMetric) descActive() {
-	current := time.Now()
-	atomic.AddUint64(&n.activeReqCount, ^uint64(0))
-	atomic.StorePointer(&n.lastActiveReqCountChangeTime, unsafe.Pointer(&current))
+func (n *resourceMetric) isThrottled() bool {
+	return time.Now().UnixNano() < atomic.LoadInt64(&n.throttledUntil)
 }
 
-func (n *resourceMetric) takeActiveReqCount() uint64 {
-	activeReqCount := atomic.LoadUint64(&n.activeReqCount)
-	if activeReqCount == 0 {
-		return 0
+func (n *resourceMetric) recordLatency(rt time.Duration) {
+	idx := atomic.AddUint64(&n.latencySampleCount, 1) - 1
+	atomic.StoreInt64(&n.latencySamples[idx%latencySampleSize], int64(rt))
+	for {
+		old := atomic.LoadInt64(&n.latency)
+		smoothed := int64(rt)
+		if old != 0 {
+			smoothed = int64(float64(old)*(1-latencyDecay) + float64(rt)*latencyDecay)
+		}
+		if atomic.CompareAndSwapInt64(&n.latency, old, smoothed) {
+			return
+		}
 	}
-	pt := atomic.LoadPointer(&n.lastActiveReqCountChangeTime)
-	lastActiveReqCountChangeTime := (*time.Time)(pt)
-	if time.Now().Sub(*lastActiveReqCountChangeTime) > n.metrics.activeReqCountWindow {
-		atomic.StoreUint64(&n.activeReqCount, 0)
+}
+
+func (n *resourceMetric) takeLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&n.latency))
+}
+
+func (n *resourceMetric) takeLatencyPercentile(percentile float64) time.Duration {
+	count := atomic.LoadUint64(&n.latencySampleCount)
+	if count == 0 {
 		return 0
 	}
-	return activeReqCount
+	if count > latencySampleSize {
+		count = latencySampleSize
+	}
+	samples := make([]int64, count)
+	for i := range samples {
+		samples[i] = atomic.LoadInt64(&n.latencySamples[i])
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+	idx := int(percentile / 100 * float64(len(samples)-1))
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(samples) {
+		idx = len(samples) - 1
+	}
+	return time.Duration(samples[idx])
+}
+
+func (n *resourceMetric) takeActiveReqCount() uint64 {
+	return atomic.LoadUint64(&n.activeReqCount)
+}
+
+func (n *resourceMetric) takeLeakedReqCount() uint64 {
+	return atomic.LoadUint64(&n.leakedReqCount)
 }
 
 func (n *resourceMetric) takeFailCount() uint64 {
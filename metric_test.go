@@ -32,3 +32,50 @@ func TestMetric_add_and_get(t *testing.T) {
 	assert.Equal(t, uint64(0), mm.takeFailCount())
 
 }
+
+func TestMetric_latencyPercentile(t *testing.T) {
+	n := Resource{Server: "1"}
+	m := newNodeMetric(func() (func() ResourceList, chan struct{}) {
+		return func() ResourceList {
+			return ResourceList{n}
+		}, make(chan struct{})
+	})
+	assert.Equal(t, time.Duration(0), m.LatencyPercentile(n, 99))
+	for i := 1; i <= 100; i++ {
+		m.takeMetric(n).recordSuccess(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, m.LatencyPercentile(n, 50))
+	assert.Equal(t, 100*time.Millisecond, m.LatencyPercentile(n, 100))
+}
+
+func TestMetric_leaseReclaim(t *testing.T) {
+	n := Resource{Server: "1"}
+	m := newNodeMetric(func() (func() ResourceList, chan struct{}) {
+		return func() ResourceList {
+			return ResourceList{n}
+		}, make(chan struct{})
+	})
+	m.leaseTimeout = 10 * time.Millisecond
+	var expired []Resource
+	m.leaseExpired = func(node Resource, held time.Duration) {
+		expired = append(expired, node)
+	}
+	released := 0
+	hung := m.takeMetric(n).acquireLease(func() {
+		released++
+	})
+	m.takeMetric(n).acquireLease(nil).release()
+	assert.Equal(t, uint64(1), m.ActiveRequests(n))
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, uint64(1), m.ActiveRequests(n))
+	m.availableServer(0)
+	assert.Equal(t, uint64(0), m.ActiveRequests(n))
+	assert.Equal(t, uint64(1), m.takeMetric(n).takeLeakedReqCount())
+	assert.Equal(t, 1, released)
+	assert.Len(t, expired, 1)
+
+	assert.False(t, hung.release())
+	assert.Equal(t, uint64(0), m.ActiveRequests(n))
+	assert.Equal(t, 1, released)
+}
@@ -0,0 +1,104 @@
+package faildep
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MySQLError is implemented by error which exposes MySQL server error number.
+//
+// `*mysql.MySQLError` of github.com/go-sql-driver/mysql doesn't implement it,
+// its `Number` field is read by type name instead, so there is no driver dependency.
+type MySQLError interface {
+	error
+	MySQLErrorNumber() uint16
+}
+
+// mysqlDriverError is type name of error returned by github.com/go-sql-driver/mysql.
+const mysqlDriverError = "*mysql.MySQLError"
+
+// MySQL server error numbers used by `MySQLErrorClassification`.
+const (
+	mysqlConCount             = 1040
+	mysqlServerShutdown       = 1053
+	mysqlTooManyUserConns     = 1203
+	mysqlLockWaitTimeout      = 1205
+	mysqlLockDeadlock         = 1213
+	mysqlOptionPreventsStmt   = 1290
+	mysqlReadOnlyMode         = 1836
+	mysqlBadNull              = 1048
+	mysqlBadField             = 1054
+	mysqlDupEntry             = 1062
+	mysqlParseError           = 1064
+	mysqlNoSuchTable          = 1146
+	mysqlRowIsReferenced      = 1451
+	mysqlNoReferencedRow      = 1452
+	mysqlCheckConstraintFails = 3819
+)
+
+// mysqlClassification is the rule set of `MySQLErrorClassification`.
+var mysqlClassification = NewClassifier().
+	Match(mysqlErrorIn(mysqlConCount, mysqlTooManyUserConns), Fail|Breakable|Retriable|OtherNode|Unsent).
+	Match(mysqlErrorIn(mysqlServerShutdown), Fail|Breakable|Retriable|OtherNode).
+	Match(mysqlErrorIn(mysqlLockDeadlock, mysqlLockWaitTimeout), Fail|Retriable|SameNode).
+	Match(mysqlErrorIn(mysqlOptionPreventsStmt, mysqlReadOnlyMode), Fail|Breakable).
+	Match(mysqlErrorIn(mysqlParseError, mysqlBadField, mysqlNoSuchTable, mysqlBadNull,
+		mysqlDupEntry, mysqlRowIsReferenced, mysqlNoReferencedRow, mysqlCheckConstraintFails), Fail).
+	Match(func(err error) bool {
+		_, ok := MySQLErrorNumber(err)
+		return ok
+	}, Fail).
+	Fallback(NetworkErrorClassification).
+	Build()
+
+// MySQLErrorClassification classify MySQL server error by error number, it can be used with `WithResponseClassifier`.
+//
+// - 1040, 1203 (too many connections) are `Fail | Breakable | Retriable | OtherNode | Unsent`.
+// - 1053 (server shutdown) is `Fail | Breakable | Retriable | OtherNode`.
+// - 1213 (deadlock) and 1205 (lock wait timeout) are `Fail | Retriable | SameNode`.
+// - 1290, 1836 (read-only) are `Fail | Breakable`.
+// - syntax, schema and constraint errors, e.g. 1064, 1062, 1452, and other server errors are `Fail`.
+// - error without MySQL error number is classified by `NetworkErrorClassification`.
+func MySQLErrorClassification(err error) RepType {
+	return mysqlClassification(err)
+}
+
+// MySQLErrorNumber returns MySQL server error number in err chain.
+func MySQLErrorNumber(err error) (uint16, bool) {
+	var number uint16
+	found := walkErrors(err, func(err error) bool {
+		if e, ok := err.(MySQLError); ok {
+			number = e.MySQLErrorNumber()
+			return true
+		}
+		if fmt.Sprintf("%T", err) != mysqlDriverError {
+			return false
+		}
+		field, ok := errorField(err, "Number")
+		if !ok {
+			return false
+		}
+		switch field.Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+			number = uint16(field.Uint())
+			return true
+		}
+		return false
+	})
+	return number, found
+}
+
+func mysqlErrorIn(numbers ...uint16) func(err error) bool {
+	return func(err error) bool {
+		number, ok := MySQLErrorNumber(err)
+		if !ok {
+			return false
+		}
+		for _, n := range numbers {
+			if n == number {
+				return true
+			}
+		}
+		return false
+	}
+}
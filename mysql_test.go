@@ -0,0 +1,57 @@
+package faildep
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// testMySQLError has the same shape as `*mysql.MySQLError` of go-sql-driver, but isn't the driver type.
+type testMySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *testMySQLError) Error() string {
+	return fmt.Sprintf("Error %d: %s", e.Number, e.Message)
+}
+
+type testMySQLCoder uint16
+
+func (e testMySQLCoder) Error() string {
+	return "mysql error"
+}
+
+func (e testMySQLCoder) MySQLErrorNumber() uint16 {
+	return uint16(e)
+}
+
+func TestMySQLErrorClassification(t *testing.T) {
+	assert.Equal(t, OK, MySQLErrorClassification(nil))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode|Unsent, MySQLErrorClassification(testMySQLCoder(1040)))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode|Unsent, MySQLErrorClassification(fmt.Errorf("exec: %w", testMySQLCoder(1203))))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode, MySQLErrorClassification(testMySQLCoder(1053)))
+	assert.Equal(t, Fail|Retriable|SameNode, MySQLErrorClassification(testMySQLCoder(1213)))
+	assert.Equal(t, Fail|Retriable|SameNode, MySQLErrorClassification(testMySQLCoder(1205)))
+	assert.Equal(t, Fail|Breakable, MySQLErrorClassification(testMySQLCoder(1290)))
+	assert.Equal(t, Fail|Breakable, MySQLErrorClassification(testMySQLCoder(1836)))
+	assert.Equal(t, Fail, MySQLErrorClassification(testMySQLCoder(1064)))
+	assert.Equal(t, Fail, MySQLErrorClassification(testMySQLCoder(1062)))
+	assert.Equal(t, Fail, MySQLErrorClassification(testMySQLCoder(9999)))
+	assert.Equal(t, Fail|Breakable|Retriable, MySQLErrorClassification(testNetError{}))
+	assert.Equal(t, Fail, MySQLErrorClassification(errors.New("unknown")))
+}
+
+func TestMySQLErrorNumber(t *testing.T) {
+	n, ok := MySQLErrorNumber(errors.Join(errors.New("rollback"), fmt.Errorf("exec: %w", testMySQLCoder(1213))))
+	assert.True(t, ok)
+	assert.Equal(t, uint16(1213), n)
+	_, ok = MySQLErrorNumber(errors.New("unknown"))
+	assert.False(t, ok)
+	_, ok = MySQLErrorNumber((*testMySQLError)(nil))
+	assert.False(t, ok)
+	_, ok = MySQLErrorNumber(&testMySQLError{Number: 1213})
+	assert.False(t, ok)
+	assert.Equal(t, Fail, MySQLErrorClassification(&testMySQLError{Number: 1040}))
+}
@@ -0,0 +1,84 @@
+package faildep
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func outcomeClassifier(typ RepType) func(err error) RepType {
+	return func(err error) RepType {
+		if err == nil {
+			return OK
+		}
+		return typ
+	}
+}
+
+func TestOutcome_sameNode(t *testing.T) {
+	f := NewFailDepStatic("testSameNode", []string{"1", "2"},
+		WithRetry(3, 2, 0, 0, NoBackoff),
+		WithResponseClassifier(outcomeClassifier(Fail|Retriable|SameNode)),
+	)
+	var tried []string
+	err := f.Do(func(node *Resource) error {
+		tried = append(tried, node.Server)
+		return errors.New("deadlock")
+	})
+	assert.Error(t, err)
+	assert.Len(t, tried, 3)
+	for _, s := range tried {
+		assert.Equal(t, tried[0], s)
+	}
+}
+
+func TestOutcome_otherNode(t *testing.T) {
+	f := NewFailDepStatic("testOtherNode", []string{"1", "2", "3"},
+		WithRetry(2, 2, 0, 0, NoBackoff),
+		WithResponseClassifier(outcomeClassifier(Fail|Retriable|OtherNode)),
+	)
+	var tried []string
+	err := f.Do(func(node *Resource) error {
+		tried = append(tried, node.Server)
+		return errors.New("too many connections")
+	})
+	assert.True(t, errors.Is(err, MaxRetryError))
+	assert.Len(t, tried, 3)
+	assert.NotEqual(t, tried[0], tried[1])
+	assert.NotEqual(t, tried[1], tried[2])
+	assert.NotEqual(t, tried[0], tried[2])
+}
+
+func TestOutcome_throttled(t *testing.T) {
+	f := NewFailDepStatic("testThrottled", []string{"1", "2"},
+		WithCircuitBreaker(1, 10*time.Second, 10*time.Second, Exponential),
+		WithRetry(1, 2, 0, 0, NoBackoff),
+		WithResponseClassifier(outcomeClassifier(Fail|Throttled|Retriable)),
+	)
+	throttled := false
+	for !throttled {
+		err := f.Do(func(node *Resource) error {
+			if node.Server == "1" {
+				throttled = true
+				return errors.New("slow down")
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.False(t, f.metrics.Tripped(Resource{Server: "1"}))
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, f.Do(func(node *Resource) error {
+			assert.Equal(t, "2", node.Server)
+			return nil
+		}))
+	}
+
+	f.metrics.takeMetric(Resource{Server: "2"}).recordThrottled(time.Second)
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, ResourceThrottledError))
+}
@@ -0,0 +1,75 @@
+package faildep
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError returns when service function panics and panic recovery is enabled.
+// It's classified by response classifier like other errors, `NetworkErrorClassification` treats it as `Fail | Breakable`.
+type PanicError struct {
+	// Value present value given to panic.
+	Value interface{}
+	// Stack present stack trace of panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("Service Panic: %v", e.Value)
+}
+
+// Unwrap returns panic value when it's an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// WithPanicRecovery configure recovering panic in service function.
+//
+// Default: panic recovery is disabled, panic in service function spreads to caller of `Do`,
+// panic in service function running on another goroutine, e.g. with attempt timeout or hedging,
+// is recovered there and panics again on caller's goroutine with `*PanicError`.
+//
+// Recovered panic becomes `*PanicError` with stack trace, it's classified by response classifier and emits `PanicRecovered` event.
+func WithPanicRecovery() func(f *FailDep) {
+	return func(f *FailDep) {
+		f.recoverPanic = true
+	}
+}
+
+func newPanicError(value interface{}) *PanicError {
+	return &PanicError{
+		Value: value,
+		Stack: debug.Stack(),
+	}
+}
+
+// recoverTo recovers panic of goroutine running attempt and sends it to results,
+// so it can panic again on caller's goroutine instead of crashing the process.
+func recoverTo(results chan<- attemptResult) {
+	if r := recover(); r != nil {
+		panicErr, ok := r.(*PanicError)
+		if !ok {
+			panicErr = newPanicError(r)
+		}
+		results <- attemptResult{finish: true, panic: panicErr}
+	}
+}
+
+// callService calls service once, and turns panic into `*PanicError` when panic recovery is enabled.
+func (f *FailDep) callService(ctx context.Context, node *Resource, service serviceFunc) (result interface{}, err error) {
+	if f.recoverPanic {
+		defer func() {
+			if r := recover(); r != nil {
+				panicErr := newPanicError(r)
+				f.logger.Error("res:", f.name, "at:", node.Server, "Panic Occured:", r, string(panicErr.Stack))
+				f.emit(PanicRecovered, node, panicErr)
+				result, err = nil, panicErr
+			}
+		}()
+	}
+	return service(ctx, node)
+}
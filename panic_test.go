@@ -0,0 +1,88 @@
+package faildep
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	var events []Event
+	f := NewFailDepStatic("testPanic", []string{"1"},
+		WithCircuitBreaker(1, 10*time.Second, 10*time.Second, Exponential),
+		WithPanicRecovery(),
+		WithEventListener(func(e Event) {
+			events = append(events, e)
+		}),
+	)
+	err := f.Do(func(node *Resource) error {
+		panic("boom")
+	})
+	var panicErr *PanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.Equal(t, "boom", panicErr.Value)
+	assert.True(t, len(panicErr.Stack) > 0)
+	assert.Len(t, events, 1)
+	assert.Equal(t, PanicRecovered, events[0].Type)
+	assert.True(t, f.metrics.Tripped(Resource{Server: "1"}))
+	assert.Equal(t, uint64(0), f.metrics.ActiveRequests(Resource{Server: "1"}))
+}
+
+func TestPanicRecovery_attemptTimeout(t *testing.T) {
+	f := NewFailDepStatic("testPanicTimeout", []string{"1", "2"},
+		WithPanicRecovery(),
+		WithAttemptTimeout(time.Second),
+		WithRetry(1, 0, 0, 0, NoBackoff),
+		WithResponseClassifier(func(err error) RepType {
+			if err == nil {
+				return OK
+			}
+			return Fail | Retriable
+		}),
+	)
+	err := f.Do(func(node *Resource) error {
+		if node.Server == "1" {
+			panic(errors.New("boom"))
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+}
+
+func recoverDo(f *FailDep, service func(node *Resource) error) (recovered interface{}) {
+	defer func() {
+		recovered = recover()
+	}()
+	f.Do(service)
+	return nil
+}
+
+func TestPanicWithoutRecovery_attemptTimeout(t *testing.T) {
+	f := NewFailDepStatic("testPanicNoRecoveryTimeout", []string{"1"},
+		WithAttemptTimeout(time.Second),
+	)
+	recovered := recoverDo(f, func(node *Resource) error {
+		panic("boom")
+	})
+	panicErr, ok := recovered.(*PanicError)
+	assert.True(t, ok, recovered)
+	if ok {
+		assert.Equal(t, "boom", panicErr.Value)
+	}
+	assert.Equal(t, uint64(0), f.metrics.ActiveRequests(Resource{Server: "1"}))
+}
+
+func TestPanicWithoutRecovery_hedging(t *testing.T) {
+	f := NewFailDepStatic("testPanicNoRecoveryHedge", []string{"1", "2"},
+		WithHedging(time.Second, 0, 1),
+	)
+	recovered := recoverDo(f, func(node *Resource) error {
+		panic("boom")
+	})
+	panicErr, ok := recovered.(*PanicError)
+	assert.True(t, ok, recovered)
+	if ok {
+		assert.Equal(t, "boom", panicErr.Value)
+	}
+}
@@ -0,0 +1,78 @@
+package faildep
+
+import (
+	"time"
+)
+
+// Filter present logic to filter servers before picking.
+// It must not modify given servers and returns a new list instead.
+type Filter func(metrics Metrics, call *CallInfo, servers ResourceList) ResourceList
+
+// NewPipelinePick returns ServerPicker which applies filters one by one and picks server using selector.
+//
+// - selector indicate picker for filtered servers, e.g. `P2CPick`, `NewRoundRobinPick()`, `HashPick`
+// - fallback indicate picker for unfiltered servers when filters leave nothing, nil means pick nothing
+// - filters indicate filters applied in order, e.g. `TagFilter`, `ZoneFilter`, `ExcludeTriedFilter`, `LatencyFilter`
+func NewPipelinePick(selector ServerPicker, fallback ServerPicker, filters ...Filter) ServerPicker {
+	return func(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
+		filtered := servers
+		for _, filter := range filters {
+			filtered = filter(metrics, call, filtered)
+			if len(filtered) == 0 {
+				break
+			}
+		}
+		if len(filtered) == 0 {
+			if fallback == nil {
+				return nil
+			}
+			return fallback(metrics, call, servers)
+		}
+		return selector(metrics, call, filtered)
+	}
+}
+
+// TagFilter keeps servers which have given tag.
+func TagFilter(tag string) Filter {
+	return func(metrics Metrics, call *CallInfo, servers ResourceList) ResourceList {
+		return filterResource(servers, func(node Resource) bool {
+			return node.HasTag(tag)
+		})
+	}
+}
+
+// ZoneFilter keeps servers whose `zone` attribute equals given zone.
+func ZoneFilter(zone string) Filter {
+	return func(metrics Metrics, call *CallInfo, servers ResourceList) ResourceList {
+		return filterResource(servers, func(node Resource) bool {
+			return node.Attr("zone") == zone
+		})
+	}
+}
+
+// ExcludeTriedFilter keeps servers which haven't been tried in current call.
+func ExcludeTriedFilter(metrics Metrics, call *CallInfo, servers ResourceList) ResourceList {
+	return filterResource(servers, func(node Resource) bool {
+		return !call.Tried.contains(node)
+	})
+}
+
+// LatencyFilter keeps servers whose smoothed latency is not beyond cutoff,
+// servers without latency sample are kept.
+func LatencyFilter(cutoff time.Duration) Filter {
+	return func(metrics Metrics, call *CallInfo, servers ResourceList) ResourceList {
+		return filterResource(servers, func(node Resource) bool {
+			return metrics.Latency(node) <= cutoff
+		})
+	}
+}
+
+func filterResource(servers ResourceList, keep func(node Resource) bool) ResourceList {
+	filtered := make(ResourceList, 0, len(servers))
+	for _, node := range servers {
+		if keep(node) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
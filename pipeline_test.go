@@ -0,0 +1,67 @@
+package faildep
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPipelinePick_filterAndFallback(t *testing.T) {
+	servers := ResourceList{
+		{index: 0, Server: "a", Attrs: map[string]string{"zone": "z1"}},
+		{index: 1, Server: "b", Tags: []string{"canary"}, Attrs: map[string]string{"zone": "z2"}},
+		{index: 2, Server: "c", Attrs: map[string]string{"zone": "z2"}},
+	}
+	m := newNodeMetric(func() (func() ResourceList, chan struct{}) {
+		return func() ResourceList {
+			return servers
+		}, make(chan struct{})
+	})
+
+	p := NewPipelinePick(P2CPick, nil, ZoneFilter("z2"), TagFilter("canary"))
+	assert.Equal(t, "b", p(m, &CallInfo{}, servers).Server)
+
+	p = NewPipelinePick(P2CPick, nil, ZoneFilter("z3"))
+	assert.Nil(t, p(m, &CallInfo{}, servers))
+
+	p = NewPipelinePick(P2CPick, RandomPick, ZoneFilter("z3"))
+	assert.NotNil(t, p(m, &CallInfo{}, servers))
+
+	p = NewPipelinePick(NewRoundRobinPick(), nil, ExcludeTriedFilter)
+	assert.Equal(t, "c", p(m, &CallInfo{Tried: servers[:2]}, servers).Server)
+
+	m.takeMetric(servers[0]).recordSuccess(100 * time.Millisecond)
+	m.takeMetric(servers[1]).recordSuccess(1 * time.Millisecond)
+	p = NewPipelinePick(NewRoundRobinPick(), nil, LatencyFilter(10*time.Millisecond), ZoneFilter("z1"))
+	assert.Nil(t, p(m, &CallInfo{}, servers))
+}
+
+func TestHashPick_stable(t *testing.T) {
+	servers := testResources(10)
+	picked := HashPick(nil, &CallInfo{AffinityKey: "user-1"}, servers)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, picked.Server, HashPick(nil, &CallInfo{AffinityKey: "user-1"}, servers).Server)
+	}
+	var others ResourceList
+	for _, s := range servers {
+		if s.Server != picked.Server && len(others) < 5 {
+			others = append(others, s)
+		}
+	}
+	others = append(others, *picked)
+	assert.Equal(t, picked.Server, HashPick(nil, &CallInfo{AffinityKey: "user-1"}, others).Server)
+}
+
+func TestNewFailDepStaticResources_tags(t *testing.T) {
+	f := NewFailDepStaticResources("testTags", ResourceList{
+		{Server: "a"},
+		{Server: "b", Tags: []string{"fast"}},
+	}, WithPickServer(NewPipelinePick(P2CPick, nil, TagFilter("fast"))))
+	for i := 0; i < 10; i++ {
+		err := f.Do(func(node *Resource) error {
+			assert.Equal(t, "b", node.Server)
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,78 @@
+package faildep
+
+import (
+	"reflect"
+	"strings"
+)
+
+// PostgresError is implemented by error which exposes PostgreSQL SQLSTATE code,
+// e.g. `*pgconn.PgError` of github.com/jackc/pgx and `*pq.Error` of github.com/lib/pq.
+//
+// Error doesn't implement it but has an exported string field `Code` is also supported, so there is no driver dependency.
+type PostgresError interface {
+	error
+	SQLState() string
+}
+
+// postgresClassification is the rule set of `PostgresErrorClassification`.
+var postgresClassification = NewClassifier().
+	Match(sqlStateIn("08001", "08004", "57P03"), Fail|Breakable|Retriable|Unsent).
+	Match(sqlStateIn("08", "57P01", "57P02"), Fail|Breakable|Retriable).
+	Match(sqlStateIn("53300"), Fail|Breakable|Retriable|OtherNode|Unsent).
+	Match(sqlStateIn("40001", "40P01"), Fail|Retriable|SameNode).
+	Match(sqlStateIn("25006"), Fail|Breakable|Retriable|OtherNode|Unsent).
+	Match(sqlStateIn("23"), Fail).
+	Match(func(err error) bool {
+		_, ok := PostgresSQLState(err)
+		return ok
+	}, Fail).
+	Fallback(NetworkErrorClassification).
+	Build()
+
+// PostgresErrorClassification classify PostgreSQL error by SQLSTATE, it can be used with `WithResponseClassifier`.
+//
+// - 08001, 08004 (connection rejected) and 57P03 (cannot connect now) are `Fail | Breakable | Retriable | Unsent`.
+// - other class 08 (connection exception), 57P01, 57P02 (shutdown) are `Fail | Breakable | Retriable`.
+// - 53300 (too many connections) is `Fail | Breakable | Retriable | OtherNode | Unsent`.
+// - 40001 (serialization failure) and 40P01 (deadlock) are `Fail | Retriable | SameNode`.
+// - 25006 (read-only transaction) is `Fail | Breakable | Retriable | OtherNode | Unsent`, so call moves to other resource.
+// - class 23 (integrity constraint violation) and other SQLSTATE are `Fail`.
+// - error without SQLSTATE is classified by `NetworkErrorClassification`.
+func PostgresErrorClassification(err error) RepType {
+	return postgresClassification(err)
+}
+
+// PostgresSQLState returns PostgreSQL SQLSTATE code in err chain.
+func PostgresSQLState(err error) (string, bool) {
+	var state string
+	found := walkErrors(err, func(err error) bool {
+		if e, ok := err.(PostgresError); ok {
+			state = e.SQLState()
+			return state != ""
+		}
+		field, ok := errorField(err, "Code")
+		if !ok || field.Kind() != reflect.String || field.Len() != 5 {
+			return false
+		}
+		state = field.String()
+		return true
+	})
+	return state, found
+}
+
+// sqlStateIn returns predicate matches SQLSTATE equals to or in class of given codes,
+// code with 2 characters present a class, e.g. "08".
+func sqlStateIn(codes ...string) func(err error) bool {
+	return func(err error) bool {
+		state, ok := PostgresSQLState(err)
+		if !ok {
+			return false
+		}
+		for _, code := range codes {
+			if state == code || (len(code) == 2 && strings.HasPrefix(state, code)) {
+				return true
+			}
+		}
+		return false
+	}
+}
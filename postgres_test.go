@@ -0,0 +1,56 @@
+package faildep
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// testPqErrorCode has the same shape as `pq.ErrorCode` of lib/pq.
+type testPqErrorCode string
+
+// testPqError has the same shape as `*pq.Error` of lib/pq before it has `SQLState` method.
+type testPqError struct {
+	Code    testPqErrorCode
+	Message string
+}
+
+func (e *testPqError) Error() string {
+	return "pq: " + e.Message
+}
+
+type testPgError struct {
+	code string
+}
+
+func (e *testPgError) Error() string {
+	return "ERROR: " + e.code
+}
+
+func (e *testPgError) SQLState() string {
+	return e.code
+}
+
+func TestPostgresErrorClassification(t *testing.T) {
+	assert.Equal(t, OK, PostgresErrorClassification(nil))
+	assert.Equal(t, Fail|Breakable|Retriable, PostgresErrorClassification(&testPgError{code: "08006"}))
+	assert.Equal(t, Fail|Breakable|Retriable, PostgresErrorClassification(&testPqError{Code: "57P01"}))
+	assert.Equal(t, Fail|Breakable|Retriable|Unsent, PostgresErrorClassification(&testPgError{code: "08001"}))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode|Unsent, PostgresErrorClassification(&testPgError{code: "53300"}))
+	assert.Equal(t, Fail|Retriable|SameNode, PostgresErrorClassification(fmt.Errorf("commit: %w", &testPgError{code: "40001"})))
+	assert.Equal(t, Fail|Retriable|SameNode, PostgresErrorClassification(&testPqError{Code: "40P01"}))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode|Unsent, PostgresErrorClassification(&testPgError{code: "25006"}))
+	assert.Equal(t, Fail, PostgresErrorClassification(&testPgError{code: "23505"}))
+	assert.Equal(t, Fail, PostgresErrorClassification(&testPgError{code: "42601"}))
+	assert.Equal(t, Fail|Breakable|Retriable, PostgresErrorClassification(testNetError{}))
+	assert.Equal(t, Fail, PostgresErrorClassification(errors.New("unknown")))
+}
+
+func TestPostgresSQLState(t *testing.T) {
+	state, ok := PostgresSQLState(fmt.Errorf("query: %w", &testPqError{Code: "23503"}))
+	assert.True(t, ok)
+	assert.Equal(t, "23503", state)
+	_, ok = PostgresSQLState(errors.New("unknown"))
+	assert.False(t, ok)
+}
@@ -20,3 +20,19 @@ func nodeToResource(n NodeProvider) ResourceProvider {
 		}, c
 	}
 }
+
+// indexResource assigns index for resources given by user.
+func indexResource(r ResourceProvider) ResourceProvider {
+	return func() (func() ResourceList, chan struct{}) {
+		res, c := r()
+		return func() ResourceList {
+			given := res()
+			resources := make(ResourceList, 0, len(given))
+			for idx, node := range given {
+				node.index = idx
+				resources = append(resources, node)
+			}
+			return resources
+		}, c
+	}
+}
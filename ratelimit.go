@@ -0,0 +1,193 @@
+package faildep
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitedError returns when rate limit is reached and request can't wait for it.
+var RateLimitedError = fmt.Errorf("Rate Limit Exceeded")
+
+// RateLimitMode present what to do when rate limit of resource is reached.
+type RateLimitMode int
+
+const (
+	// RateLimitWait waits for token until context deadline.
+	RateLimitWait RateLimitMode = iota
+	// RateLimitRepick picks other resource which still has token,
+	// FailDep-wide rate limit returns `RateLimitedError` immediately in this mode.
+	RateLimitRepick
+)
+
+// WithRateLimit configure token bucket rate limit of the whole FailDep, every attempt takes a token.
+//
+// Default: rate limit is disabled, we must use this OptFunc to enable it.
+//
+// - qps indicate tokens refilled per second.
+// - burst indicate maximum tokens can be saved.
+func WithRateLimit(qps float64, burst int) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.rateLimit.global = newTokenBucket(qps, burst)
+	}
+}
+
+// WithResourceRateLimit configure token bucket rate limit of every resource, every attempt on resource takes its token.
+//
+// Default: rate limit is disabled, we must use this OptFunc to enable it.
+//
+// - qps indicate tokens refilled per second for one resource.
+// - burst indicate maximum tokens can be saved for one resource.
+// - qpsAttr indicate attribute name of resource which overrides qps, e.g. `qps`, empty means always use qps.
+func WithResourceRateLimit(qps float64, burst int, qpsAttr string) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.rateLimit.perResource = true
+		f.rateLimit.qps = qps
+		f.rateLimit.burst = burst
+		f.rateLimit.qpsAttr = qpsAttr
+		f.rateLimit.buckets = make(map[string]*tokenBucket)
+	}
+}
+
+// WithRateLimitMode configure what to do when rate limit is reached.
+// Default use `RateLimitWait`.
+func WithRateLimitMode(mode RateLimitMode) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.rateLimit.mode = mode
+	}
+}
+
+type rateLimit struct {
+	lock        sync.Mutex
+	mode        RateLimitMode
+	global      *tokenBucket
+	perResource bool
+	qps         float64
+	burst       int
+	qpsAttr     string
+	buckets     map[string]*tokenBucket
+}
+
+func (r *rateLimit) enabled() bool {
+	return r.global != nil || r.perResource
+}
+
+func (r *rateLimit) takeBucket(node Resource) *tokenBucket {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	b, ok := r.buckets[node.Server]
+	if !ok {
+		qps := r.qps
+		if r.qpsAttr != "" {
+			if v, err := strconv.ParseFloat(node.Attr(r.qpsAttr), 64); err == nil {
+				qps = v
+			}
+		}
+		b = newTokenBucket(qps, r.burst)
+		r.buckets[node.Server] = b
+	}
+	return b
+}
+
+// exhausted returns whether resource has no token in `RateLimitRepick` mode.
+func (r *rateLimit) exhausted(node Resource) bool {
+	if !r.perResource || r.mode != RateLimitRepick {
+		return false
+	}
+	return !r.takeBucket(node).available()
+}
+
+// acquire takes token of FailDep and node before attempt,
+// it returns repick is true when only node's token is used up in `RateLimitRepick` mode.
+// - member indicate node is in resource list, node's token is only taken for member.
+func (r *rateLimit) acquire(ctx context.Context, node Resource, member bool) (repick bool, err error) {
+	wait := r.mode == RateLimitWait
+	if r.global != nil {
+		if err = r.global.acquire(ctx, wait); err != nil {
+			return false, err
+		}
+	}
+	if r.perResource && member {
+		if err = r.takeBucket(node).acquire(ctx, wait); err != nil {
+			if r.global != nil {
+				r.global.refund()
+			}
+			return err == RateLimitedError && !wait, err
+		}
+	}
+	return false, nil
+}
+
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(qps float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   qps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) available() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.refill(time.Now())
+	return b.tokens >= 1
+}
+
+func (b *tokenBucket) refund() {
+	b.lock.Lock()
+	b.tokens++
+	b.lock.Unlock()
+}
+
+// acquire takes a token, waits for it until ctx deadline when wait is true.
+func (b *tokenBucket) acquire(ctx context.Context, wait bool) error {
+	b.lock.Lock()
+	now := time.Now()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		b.lock.Unlock()
+		return nil
+	}
+	if !wait || b.rate <= 0 {
+		b.lock.Unlock()
+		return RateLimitedError
+	}
+	delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		b.lock.Unlock()
+		return RateLimitedError
+	}
+	// reserve token, it will be refunded when ctx is done before token is ready.
+	b.tokens--
+	b.lock.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		b.refund()
+		return ctx.Err()
+	}
+}
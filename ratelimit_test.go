@@ -0,0 +1,57 @@
+package faildep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_waitUntilDeadline(t *testing.T) {
+	b := newTokenBucket(100, 1)
+	assert.NoError(t, b.acquire(context.Background(), false))
+	assert.Equal(t, RateLimitedError, b.acquire(context.Background(), false))
+
+	startTime := time.Now()
+	assert.NoError(t, b.acquire(context.Background(), true))
+	assert.True(t, time.Now().Sub(startTime) >= 5*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.Equal(t, RateLimitedError, b.acquire(ctx, true))
+}
+
+func TestResourceRateLimit_repick(t *testing.T) {
+	f := NewFailDepStaticResources("testRateLimit", ResourceList{
+		{Server: "1", Attrs: map[string]string{"qps": "0.001"}},
+		{Server: "2", Attrs: map[string]string{"qps": "0.001"}},
+	}, WithResourceRateLimit(1000, 1, "qps"), WithRateLimitMode(RateLimitRepick))
+
+	used := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		err := f.Do(func(node *Resource) error {
+			used[node.Server] = true
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.Len(t, used, 2)
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.True(t, errors.Is(err, RateLimitedError))
+}
+
+func TestRateLimit_global(t *testing.T) {
+	f := NewFailDepStatic("testGlobalRateLimit", []string{"1", "2"},
+		WithRateLimit(0.001, 1), WithRateLimitMode(RateLimitRepick),
+		WithRetry(3, 0, 0, 0, NoBackoff),
+	)
+	assert.NoError(t, f.Do(func(node *Resource) error {
+		return nil
+	}))
+	assert.Equal(t, RateLimitedError, f.Do(func(node *Resource) error {
+		return nil
+	}))
+}
@@ -0,0 +1,112 @@
+package faildep
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxRedirects indicate maximum redirects followed by one server pick.
+const maxRedirects = 3
+
+// RedirectAttr is attribute set on redirected resource, its value is redirect kind, e.g. `MOVED` or `ASK`.
+// Service function can use it to send Redis `ASKING` before command.
+const RedirectAttr = "redirect"
+
+// TooManyRedirectsError returns when resources keep redirecting request beyond maxRedirects.
+var TooManyRedirectsError = fmt.Errorf("Too Many Redirects")
+
+// RedirectError is implemented by error which asks to redo request on another resource,
+// response classified as `Redirect` will be redone on resource whose `Server` is `RedirectAddr()`.
+//
+// Redis `MOVED` and `ASK` errors are also supported without implementing it.
+//
+// Resource which isn't in resource list is only attempted without keeping its metrics,
+// e.g. circuit breaker, bulkhead and per-resource limits aren't applied to it.
+type RedirectError interface {
+	error
+	RedirectAddr() string
+}
+
+// redisClassification is the rule set of `RedisErrorClassification`.
+var redisClassification = NewClassifier().
+	Match(redisErrorIn("MOVED", "ASK"), Fail|Redirect).
+	Match(redisErrorIn("LOADING", "READONLY"), Fail|Breakable|Retriable|OtherNode|Unsent).
+	Match(redisErrorIn("CLUSTERDOWN", "MASTERDOWN"), Fail|Breakable|Retriable|Unsent).
+	Match(redisErrorIn("TRYAGAIN"), Fail|Retriable|SameNode|Unsent).
+	Match(func(err error) bool {
+		_, ok := err.(RedirectError)
+		return ok
+	}, Fail|Redirect).
+	Fallback(NetworkErrorClassification).
+	Build()
+
+// RedisErrorClassification classify Redis error reply by its prefix, it can be used with `WithResponseClassifier`.
+//
+// - MOVED and ASK are `Fail | Redirect`, request is redone on resource named by error without counting as failure.
+// - LOADING and READONLY are `Fail | Breakable | Retriable | OtherNode | Unsent`.
+// - CLUSTERDOWN and MASTERDOWN are `Fail | Breakable | Retriable | Unsent`.
+// - TRYAGAIN is `Fail | Retriable | SameNode | Unsent`.
+//
+// Commands rejected by these replies aren't executed, so they're `Unsent`.
+// - other error, e.g. `ERR`, `WRONGTYPE`, is classified by `NetworkErrorClassification`, which returns `Fail` for non-network error.
+func RedisErrorClassification(err error) RepType {
+	return redisClassification(err)
+}
+
+// redisError returns prefix and fields of Redis error reply in err chain.
+func redisError(err error, prefixes ...string) (fields []string, ok bool) {
+	found := walkErrors(err, func(err error) bool {
+		msg := err.Error()
+		for _, prefix := range prefixes {
+			if msg == prefix || strings.HasPrefix(msg, prefix+" ") {
+				fields = strings.Fields(msg)
+				return true
+			}
+		}
+		return false
+	})
+	return fields, found
+}
+
+func redisErrorIn(prefixes ...string) func(err error) bool {
+	return func(err error) bool {
+		_, ok := redisError(err, prefixes...)
+		return ok
+	}
+}
+
+// redirectTarget returns resource asked by redirect error,
+// it's a temporary resource when it isn't in resource list.
+func (f *FailDep) redirectTarget(err error) (*Resource, bool) {
+	var addr, kind string
+	walkErrors(err, func(err error) bool {
+		if e, ok := err.(RedirectError); ok {
+			addr = e.RedirectAddr()
+			return true
+		}
+		return false
+	})
+	if addr == "" {
+		fields, ok := redisError(err, "MOVED", "ASK")
+		if !ok || len(fields) < 3 {
+			return nil, false
+		}
+		kind, addr = fields[0], fields[2]
+	}
+	target := Resource{Server: addr}
+	for _, res := range f.metrics.allServers() {
+		if res.Server == addr {
+			target = res
+			break
+		}
+	}
+	if kind != "" {
+		attrs := make(map[string]string, len(target.Attrs)+1)
+		for k, v := range target.Attrs {
+			attrs[k] = v
+		}
+		attrs[RedirectAttr] = kind
+		target.Attrs = attrs
+	}
+	return &target, true
+}
@@ -0,0 +1,89 @@
+package faildep
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRedisErrorClassification(t *testing.T) {
+	assert.Equal(t, OK, RedisErrorClassification(nil))
+	assert.Equal(t, Fail|Redirect, RedisErrorClassification(errors.New("MOVED 3999 127.0.0.1:6381")))
+	assert.Equal(t, Fail|Redirect, RedisErrorClassification(fmt.Errorf("get: %w", errors.New("ASK 3999 127.0.0.1:6381"))))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode|Unsent, RedisErrorClassification(errors.New("LOADING Redis is loading the dataset in memory")))
+	assert.Equal(t, Fail|Breakable|Retriable|OtherNode|Unsent, RedisErrorClassification(errors.New("READONLY You can't write against a read only replica.")))
+	assert.Equal(t, Fail|Breakable|Retriable|Unsent, RedisErrorClassification(errors.New("CLUSTERDOWN The cluster is down")))
+	assert.Equal(t, Fail|Retriable|SameNode|Unsent, RedisErrorClassification(errors.New("TRYAGAIN Multiple keys request during rehashing of slot")))
+	assert.Equal(t, Fail, RedisErrorClassification(errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")))
+	assert.Equal(t, Fail, RedisErrorClassification(errors.New("ASKING is not allowed")))
+}
+
+func TestRedirect(t *testing.T) {
+	f := NewFailDepStatic("testRedirect", []string{"1", "2"},
+		WithCircuitBreaker(1, 10*time.Second, 10*time.Second, Exponential),
+		WithResponseClassifier(RedisErrorClassification),
+	)
+	var tried []string
+	var kind string
+	for i := 0; i < 10; i++ {
+		tried = tried[:0]
+		err := f.Do(func(node *Resource) error {
+			tried = append(tried, node.Server)
+			switch node.Server {
+			case "1", "2":
+				return errors.New("ASK 3999 3")
+			}
+			kind = node.Attr(RedirectAttr)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Len(t, tried, 2)
+		assert.Equal(t, "3", tried[1])
+		assert.Equal(t, "ASK", kind)
+	}
+	assert.Equal(t, uint64(0), f.metrics.SuccessiveFailures(Resource{Server: "1"}))
+	assert.Equal(t, uint64(0), f.metrics.SuccessiveFailures(Resource{Server: "2"}))
+}
+
+func TestRedirect_tooMany(t *testing.T) {
+	f := NewFailDepStatic("testRedirectTooMany", []string{"1"},
+		WithResponseClassifier(RedisErrorClassification),
+	)
+	calls := 0
+	err := f.Do(func(node *Resource) error {
+		calls++
+		return errors.New("MOVED 3999 1")
+	})
+	assert.True(t, errors.Is(err, TooManyRedirectsError))
+	assert.Equal(t, maxRedirects+1, calls)
+}
+
+func TestRedirect_targetNotKept(t *testing.T) {
+	f := NewFailDepStatic("testRedirectNotKept", []string{"1"},
+		WithCircuitBreaker(1, 10*time.Second, 10*time.Second, Exponential),
+		WithBulkheadQueue(1, 10, time.Second),
+		WithResourceRateLimit(1000, 10, "qps"),
+		WithAdaptiveLimit(func() LimitAlgorithm {
+			return NewAIMDLimit(4, 1, 10, 0.5, time.Second)
+		}),
+		WithAdaptiveThrottle(2, 10*time.Second, true),
+		WithResponseClassifier(RedisErrorClassification),
+	)
+	for i := 0; i < 5; i++ {
+		target := fmt.Sprintf("10.0.0.%d:6379", i)
+		err := f.Do(func(node *Resource) error {
+			if node.Server == "1" {
+				return fmt.Errorf("MOVED 3999 %s", target)
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.Len(t, f.metrics.metrics, 1)
+	assert.Len(t, f.bulkheads.sems, 1)
+	assert.Len(t, f.rateLimit.buckets, 1)
+	assert.Len(t, f.limits.limits, 1)
+	assert.Len(t, f.throttle.windows, 1)
+}
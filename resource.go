@@ -6,6 +6,27 @@ type Resource struct {
 	// Server present server name.
 	// e.g. 0.0.0.0:9999
 	Server string
+	// Tags present labels of resource.
+	// e.g. canary
+	Tags []string
+	// Attrs present attributes of resource.
+	// e.g. zone: us-east-1a
+	Attrs map[string]string
+}
+
+// HasTag returns whether resource has given tag.
+func (r Resource) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Attr returns attribute value of given key, it's empty when attribute is not set.
+func (r Resource) Attr(key string) string {
+	return r.Attrs[key]
 }
 
 // ResourceList present resource node list.
@@ -22,3 +43,12 @@ func (l *ResourceList) nodeIndex(res *Resource) int {
 	}
 	return -1
 }
+
+func (l ResourceList) contains(res Resource) bool {
+	for _, s := range l {
+		if s.Server == res.Server {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package faildep
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Classification present how a response is classified by `ResultClassifier`.
+type Classification struct {
+	// Type present response type.
+	Type RepType
+	// RetryAfter present minimum backOff before next retry on same resource, 0 means use retry backOff only.
+	RetryAfter time.Duration
+	// NonIdempotent present request of response isn't idempotent, e.g. POST without `Idempotency-Key` header,
+	// it's only retried when response is `Unsent`, unless call is marked by `WithIdempotent(true)`.
+	NonIdempotent bool
+}
+
+// ResultClassifier classify response by both result and error returned by service function.
+type ResultClassifier func(result interface{}, err error) Classification
+
+// WithResultClassifier config result-aware response classification, it takes precedence over `WithResponseClassifier`.
+//
+// Default: response is classified only by error with classifier given by `WithResponseClassifier`.
+//
+// - classifier indicate which classifier use to classify result and error, e.g. `HTTPClassification`.
+func WithResultClassifier(classifier ResultClassifier) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.resultClassify = classifier
+	}
+}
+
+// FailedResultError present attempt whose result is classified as failure but service function returns no error,
+// e.g. HTTP response with 503 status.
+// It's recorded in `Attempt.Err`.
+type FailedResultError struct {
+	// Result present result of the attempt, its resource (e.g. http body) has been released.
+	Result interface{}
+}
+
+func (e *FailedResultError) Error() string {
+	if resp, ok := e.Result.(*http.Response); ok {
+		return fmt.Sprintf("Failed Result: HTTP %d", resp.StatusCode)
+	}
+	return fmt.Sprintf("Failed Result: %T", e.Result)
+}
+
+type serviceFunc func(ctx context.Context, node *Resource) (interface{}, error)
+
+func (f *FailDep) classify(result interface{}, err error) Classification {
+	if err == AttemptTimeoutError {
+		return Classification{Type: Fail | Retriable | Breakable}
+	}
+	if f.resultClassify != nil {
+		return f.resultClassify(result, err)
+	}
+	return Classification{Type: f.repClassify(err)}
+}
+
+// discardResult releases result which won't be returned to caller, e.g. result of retried attempt.
+func discardResult(result interface{}) {
+	switch r := result.(type) {
+	case *http.Response:
+		if r != nil && r.Body != nil {
+			r.Body.Close()
+		}
+	case io.Closer:
+		r.Close()
+	}
+}
+
+// bindCancel ties cancel to lifetime of result which will be returned to caller,
+// e.g. context given to service is cancelled when body of `*http.Response` is closed,
+// so caller can still read the body after attempt returns.
+// cancel is called immediately when result can't hold it.
+func bindCancel(result interface{}, cancel context.CancelFunc) {
+	if r, ok := result.(*http.Response); ok && r != nil && r.Body != nil && r.Body != http.NoBody {
+		r.Body = &cancelBody{ReadCloser: r.Body, cancel: cancel}
+		return
+	}
+	cancel()
+}
+
+// cancelBody calls cancel after body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,416 @@
+package faildep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/faildep/faildep-log"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ClassifierRules present declarative classifier config, e.g.
+//
+//	{
+//	  "rules": [
+//	    {"code": [1040, 1203], "result": ["fail", "breakable", "retriable", "otherNode"]},
+//	    {"type": "*net.OpError", "message": "connection refused", "result": ["fail", "breakable", "retriable"]},
+//	    {"sentinel": "io.ErrUnexpectedEOF", "result": ["fail", "retriable"]},
+//	    {"httpStatus": [429], "result": ["fail", "throttled", "retriable"]}
+//	  ],
+//	  "default": ["fail"]
+//	}
+//
+// or the same rules in YAML:
+//
+//	rules:
+//	  - code: [1040, 1203]
+//	    result: [fail, breakable, retriable, otherNode]
+//	  - type: "*net.OpError"
+//	    message: connection refused
+//	    result: [fail, breakable, retriable]
+//	default: [fail]
+type ClassifierRules struct {
+	// Rules present rules checked in order, the first matched rule decides response type.
+	Rules []ClassifierRule `json:"rules" yaml:"rules"`
+	// Default present response type of error matches no rule, `NetworkErrorClassification` is used when it's empty.
+	Default []string `json:"default,omitempty" yaml:"default,omitempty"`
+}
+
+// ClassifierRule present one rule, it matches when all given matchers match.
+type ClassifierRule struct {
+	// Type matches type name of error in error chain, formatted like `%T`, e.g. `*net.OpError`.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Message matches error message by regexp.
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	// Sentinel matches wrapped sentinel error by `errors.Is`, e.g. `io.EOF`, `syscall.ECONNREFUSED`.
+	Sentinel string `json:"sentinel,omitempty" yaml:"sentinel,omitempty"`
+	// Code matches numeric error code, e.g. MySQL error number or integer `Code` field.
+	Code []int64 `json:"code,omitempty" yaml:"code,omitempty"`
+	// HTTPStatus matches error which exposes HTTP status by `StatusCode() int` method or integer `StatusCode` field.
+	HTTPStatus []int `json:"httpStatus,omitempty" yaml:"httpStatus,omitempty"`
+	// Result present response type flags, in ok, fail, breakable, retriable, redirect, throttled, sameNode, otherNode, unsent.
+	Result []string `json:"result" yaml:"result"`
+}
+
+// ruleSentinels present sentinel errors can be referred by `ClassifierRule.Sentinel`.
+var ruleSentinels = map[string]error{
+	"io.EOF":                       io.EOF,
+	"io.ErrUnexpectedEOF":          io.ErrUnexpectedEOF,
+	"context.Canceled":             context.Canceled,
+	"context.DeadlineExceeded":     context.DeadlineExceeded,
+	"net.ErrClosed":                net.ErrClosed,
+	"os.ErrDeadlineExceeded":       os.ErrDeadlineExceeded,
+	"syscall.ECONNREFUSED":         syscall.ECONNREFUSED,
+	"syscall.ECONNRESET":           syscall.ECONNRESET,
+	"syscall.ECONNABORTED":         syscall.ECONNABORTED,
+	"syscall.ETIMEDOUT":            syscall.ETIMEDOUT,
+	"syscall.EPIPE":                syscall.EPIPE,
+	"faildep.AttemptTimeoutError":  AttemptTimeoutError,
+	"faildep.BulkheadFullError":    BulkheadFullError,
+	"faildep.RateLimitedError":     RateLimitedError,
+	"faildep.ClientThrottledError": ClientThrottledError,
+}
+
+var ruleResults = map[string]RepType{
+	"ok":        OK,
+	"fail":      Fail,
+	"breakable": Breakable,
+	"retriable": Retriable,
+	"redirect":  Redirect,
+	"throttled": Throttled,
+	"samenode":  SameNode,
+	"othernode": OtherNode,
+	"unsent":    Unsent,
+}
+
+// CompileClassifierRules compiles JSON rules into classifier can be used with `WithResponseClassifier`.
+//
+// It returns error tells which rule is invalid, e.g. unknown field, bad regexp, unknown sentinel or result.
+//
+// - sentinels indicate extra sentinel errors can be referred by name in rules, it can be nil.
+func CompileClassifierRules(data []byte, sentinels map[string]error) (func(err error) RepType, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var rules ClassifierRules
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid classifier rules: %v", err)
+	}
+	return rules.Compile(sentinels)
+}
+
+// CompileClassifierRulesYAML compiles YAML rules like `CompileClassifierRules`.
+func CompileClassifierRulesYAML(data []byte, sentinels map[string]error) (func(err error) RepType, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var rules ClassifierRules
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("invalid classifier rules: %v", err)
+	}
+	return rules.Compile(sentinels)
+}
+
+// Compile compiles rules into classifier can be used with `WithResponseClassifier`.
+func (r ClassifierRules) Compile(sentinels map[string]error) (func(err error) RepType, error) {
+	builder := NewClassifier()
+	for i, rule := range r.Rules {
+		match, err := rule.compile(sentinels)
+		if err != nil {
+			return nil, fmt.Errorf("invalid classifier rule %d: %v", i, err)
+		}
+		typ, err := parseRuleResult(rule.Result)
+		if err != nil {
+			return nil, fmt.Errorf("invalid classifier rule %d: %v", i, err)
+		}
+		builder.Match(match, typ)
+	}
+	if len(r.Default) == 0 {
+		return builder.Fallback(NetworkErrorClassification).Build(), nil
+	}
+	typ, err := parseRuleResult(r.Default)
+	if err != nil {
+		return nil, fmt.Errorf("invalid classifier default: %v", err)
+	}
+	return builder.Fallback(func(err error) RepType {
+		return typ
+	}).Build(), nil
+}
+
+func parseRuleResult(result []string) (RepType, error) {
+	if len(result) == 0 {
+		return 0, fmt.Errorf("result is empty")
+	}
+	var typ RepType
+	for _, name := range result {
+		flag, ok := ruleResults[strings.ToLower(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown result %q", name)
+		}
+		typ |= flag
+	}
+	if typ&OK == OK && typ != OK {
+		return 0, fmt.Errorf("result ok can't be combined with other results")
+	}
+	if typ&OK != OK && typ&Fail != Fail {
+		return 0, fmt.Errorf("result must contain ok or fail")
+	}
+	return typ, nil
+}
+
+func (rule ClassifierRule) compile(sentinels map[string]error) (func(err error) bool, error) {
+	var matchers []func(err error) bool
+	if rule.Type != "" {
+		typeName := rule.Type
+		matchers = append(matchers, func(err error) bool {
+			return walkErrors(err, func(err error) bool {
+				return fmt.Sprintf("%T", err) == typeName
+			})
+		})
+	}
+	if rule.Message != "" {
+		re, err := regexp.Compile(rule.Message)
+		if err != nil {
+			return nil, fmt.Errorf("bad message regexp %q: %v", rule.Message, err)
+		}
+		matchers = append(matchers, func(err error) bool {
+			return re.MatchString(err.Error())
+		})
+	}
+	if rule.Sentinel != "" {
+		sentinel, ok := sentinels[rule.Sentinel]
+		if !ok {
+			sentinel, ok = ruleSentinels[rule.Sentinel]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown sentinel %q", rule.Sentinel)
+		}
+		matchers = append(matchers, func(err error) bool {
+			return errors.Is(err, sentinel)
+		})
+	}
+	if len(rule.Code) > 0 {
+		codes := rule.Code
+		matchers = append(matchers, func(err error) bool {
+			code, ok := errorCode(err)
+			return ok && containsInt64(codes, code)
+		})
+	}
+	if len(rule.HTTPStatus) > 0 {
+		statuses := make([]int64, 0, len(rule.HTTPStatus))
+		for _, status := range rule.HTTPStatus {
+			if status < 100 || status > 599 {
+				return nil, fmt.Errorf("bad http status %d", status)
+			}
+			statuses = append(statuses, int64(status))
+		}
+		matchers = append(matchers, func(err error) bool {
+			status, ok := errorHTTPStatus(err)
+			return ok && containsInt64(statuses, status)
+		})
+	}
+	if len(matchers) == 0 {
+		return nil, fmt.Errorf("no matcher is given, need one of type, message, sentinel, code and httpStatus")
+	}
+	return func(err error) bool {
+		for _, match := range matchers {
+			if !match(err) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func containsInt64(values []int64, v int64) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// errorCode returns numeric error code in err chain, e.g. MySQL error number.
+func errorCode(err error) (int64, bool) {
+	if number, ok := MySQLErrorNumber(err); ok {
+		return int64(number), true
+	}
+	return errorIntField(err, "Code")
+}
+
+// errorHTTPStatus returns HTTP status exposed by error in err chain.
+func errorHTTPStatus(err error) (int64, bool) {
+	var status int64
+	found := walkErrors(err, func(err error) bool {
+		if e, ok := err.(interface{ StatusCode() int }); ok {
+			status = int64(e.StatusCode())
+			return true
+		}
+		return false
+	})
+	if found {
+		return status, true
+	}
+	return errorIntField(err, "StatusCode")
+}
+
+// errorIntField returns value of integer field with given name of error in err chain.
+func errorIntField(err error, name string) (int64, bool) {
+	var value int64
+	found := walkErrors(err, func(err error) bool {
+		field, ok := errorField(err, name)
+		if !ok {
+			return false
+		}
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value = field.Int()
+			return true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value = int64(field.Uint())
+			return true
+		}
+		return false
+	})
+	return value, found
+}
+
+// RuleClassifier classify response with rules loaded from JSON or YAML file, and reloads rules when file changes.
+// Rules are kept unchanged when reloaded file is invalid.
+type RuleClassifier struct {
+	path      string
+	sentinels map[string]error
+	classify  atomic.Value
+	lock      sync.Mutex
+	modTime   time.Time
+	size      int64
+	stop      chan struct{}
+	closeOnce sync.Once
+	logger    log.Logger
+}
+
+// WithRuleLogger configure logger used to report reloading of rules.
+//
+// Default: `log.StdLogger` is used.
+//
+// - logger indicate logger of `RuleClassifier`.
+func WithRuleLogger(logger log.Logger) func(c *RuleClassifier) {
+	return func(c *RuleClassifier) {
+		c.logger = logger
+	}
+}
+
+// NewRuleClassifier loads rules from JSON or YAML file, and checks file change every reloadInterval,
+// use `WithResponseClassifier(c.Classify)` to apply it.
+//
+// - path indicate rule file path, file with `.yaml` or `.yml` extension is YAML, otherwise it's JSON, see `ClassifierRules` for format.
+// - reloadInterval indicate how often file is checked, 0 means never reload.
+// - sentinels indicate extra sentinel errors can be referred by name in rules, it can be nil.
+func NewRuleClassifier(path string, reloadInterval time.Duration, sentinels map[string]error, opts ...func(c *RuleClassifier)) (*RuleClassifier, error) {
+	c := &RuleClassifier{
+		path:      path,
+		sentinels: sentinels,
+		stop:      make(chan struct{}),
+		logger:    &log.StdLogger{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	if reloadInterval > 0 {
+		go c.watch(reloadInterval)
+	}
+	return c, nil
+}
+
+// Classify classify error with current rules.
+func (c *RuleClassifier) Classify(err error) RepType {
+	return c.classify.Load().(func(err error) RepType)(err)
+}
+
+// Reload loads rules from file immediately, current rules are kept when it returns error.
+func (c *RuleClassifier) Reload() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stat, err := os.Stat(c.path)
+	if err != nil {
+		return err
+	}
+	return c.load(stat)
+}
+
+func (c *RuleClassifier) load(stat os.FileInfo) error {
+	c.modTime = stat.ModTime()
+	c.size = stat.Size()
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return err
+	}
+	compile := CompileClassifierRules
+	switch strings.ToLower(filepath.Ext(c.path)) {
+	case ".yaml", ".yml":
+		compile = CompileClassifierRulesYAML
+	}
+	classify, err := compile(data, c.sentinels)
+	if err != nil {
+		return fmt.Errorf("%s: %v", c.path, err)
+	}
+	c.classify.Store(classify)
+	return nil
+}
+
+// Close stops reloading rules.
+func (c *RuleClassifier) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+}
+
+func (c *RuleClassifier) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+		reloaded, err := c.reloadChanged()
+		if err != nil {
+			c.logger.Error("rules:", c.path, "error:", err)
+		} else if reloaded {
+			c.logger.Info("rules:", c.path, "reloaded")
+		}
+	}
+}
+
+// reloadChanged reloads rules when file has changed since last load.
+func (c *RuleClassifier) reloadChanged() (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stat, err := os.Stat(c.path)
+	if err != nil {
+		return false, err
+	}
+	if stat.ModTime().Equal(c.modTime) && stat.Size() == c.size {
+		return false, nil
+	}
+	if err := c.load(stat); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,171 @@
+package faildep
+
+import (
+	"errors"
+	"fmt"
+	"github.com/faildep/faildep-log"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testStatusError struct {
+	StatusCode int
+}
+
+func (e *testStatusError) Error() string {
+	return fmt.Sprintf("status %d", e.StatusCode)
+}
+
+var errTestSentinel = errors.New("custom sentinel")
+
+func TestCompileClassifierRules(t *testing.T) {
+	classify, err := CompileClassifierRules([]byte(`{
+		"rules": [
+			{"code": [1213], "result": ["fail", "retriable", "sameNode"]},
+			{"type": "*faildep.testStatusError", "httpStatus": [429], "result": ["fail", "throttled", "retriable"]},
+			{"message": "^timeout", "result": ["fail", "breakable", "retriable"]},
+			{"sentinel": "io.ErrUnexpectedEOF", "result": ["Fail", "Retriable"]},
+			{"sentinel": "custom", "result": ["fail"]}
+		],
+		"default": ["fail", "breakable"]
+	}`), map[string]error{"custom": errTestSentinel})
+	assert.NoError(t, err)
+	assert.Equal(t, OK, classify(nil))
+	assert.Equal(t, Fail|Retriable|SameNode, classify(fmt.Errorf("exec: %w", testMySQLCoder(1213))))
+	assert.Equal(t, Fail|Throttled|Retriable, classify(&testStatusError{StatusCode: 429}))
+	assert.Equal(t, Fail|Breakable, classify(&testStatusError{StatusCode: 500}))
+	assert.Equal(t, Fail|Breakable|Retriable, classify(errors.New("timeout after 1s")))
+	assert.Equal(t, Fail|Retriable, classify(fmt.Errorf("read: %w", io.ErrUnexpectedEOF)))
+	assert.Equal(t, Fail, classify(fmt.Errorf("call: %w", errTestSentinel)))
+	assert.Equal(t, Fail|Breakable, classify(errors.New("unknown")))
+}
+
+func TestCompileClassifierRules_invalid(t *testing.T) {
+	cases := map[string]string{
+		`{"rules": [{"message": "(", "result": ["fail"]}]}`:                                      "invalid classifier rule 0: bad message regexp",
+		`{"rules": [{"sentinel": "io.Nope", "result": ["fail"]}]}`:                               `invalid classifier rule 0: unknown sentinel "io.Nope"`,
+		`{"rules": [{"code": [1], "result": ["fail"]}, {"code": [2], "result": ["retryable"]}]}`: `invalid classifier rule 1: unknown result "retryable"`,
+		`{"rules": [{"result": ["fail"]}]}`:                                                      "invalid classifier rule 0: no matcher",
+		`{"rules": [{"code": [1], "result": []}]}`:                                               "invalid classifier rule 0: result is empty",
+		`{"rules": [{"code": [1], "result": ["ok", "retriable"]}]}`:                              "invalid classifier rule 0: result ok can't be combined",
+		`{"rules": [{"code": [1], "result": ["retriable"]}]}`:                                    "invalid classifier rule 0: result must contain ok or fail",
+		`{"rules": [{"httpStatus": [999], "result": ["fail"]}]}`:                                 "invalid classifier rule 0: bad http status 999",
+		`{"rules": [{"cod": [1], "result": ["fail"]}]}`:                                          "invalid classifier rules: json: unknown field",
+		`{"rules": [], "default": ["nope"]}`:                                                     `invalid classifier default: unknown result "nope"`,
+	}
+	for data, expected := range cases {
+		_, err := CompileClassifierRules([]byte(data), nil)
+		assert.Error(t, err, data)
+		if err != nil {
+			assert.True(t, strings.HasPrefix(err.Error(), expected), err.Error())
+		}
+	}
+}
+
+func TestRuleClassifier_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	write := func(data string, mtime time.Time) {
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	now := time.Now()
+	write(`{"rules": [{"message": "busy", "result": ["fail"]}]}`, now.Add(-time.Hour))
+
+	c, err := NewRuleClassifier(path, 10*time.Millisecond, nil)
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, Fail, c.Classify(errors.New("busy")))
+
+	write(`{"rules": [{"message": "busy", "result": ["fail", "retriable"]}]}`, now)
+	for i := 0; i < 100 && c.Classify(errors.New("busy")) != Fail|Retriable; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, Fail|Retriable, c.Classify(errors.New("busy")))
+
+	write(`{"rules": [{"message": "(", "result": ["fail"]}]}`, now.Add(time.Hour))
+	assert.Error(t, c.Reload())
+	assert.Equal(t, Fail|Retriable, c.Classify(errors.New("busy")))
+
+	_, err = NewRuleClassifier(path, 0, nil)
+	assert.Error(t, err)
+}
+
+func TestCompileClassifierRulesYAML(t *testing.T) {
+	classify, err := CompileClassifierRulesYAML([]byte(`
+rules:
+  - code: [1213]
+    result: [fail, retriable, sameNode]
+  - type: "*faildep.testStatusError"
+    httpStatus: [503]
+    result: [fail, breakable, retriable]
+  - sentinel: io.ErrUnexpectedEOF
+    result: [fail, retriable]
+default: [fail]
+`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Fail|Retriable|SameNode, classify(testMySQLCoder(1213)))
+	assert.Equal(t, Fail|Breakable|Retriable, classify(&testStatusError{StatusCode: 503}))
+	assert.Equal(t, Fail|Retriable, classify(fmt.Errorf("read: %w", io.ErrUnexpectedEOF)))
+	assert.Equal(t, Fail, classify(errors.New("unknown")))
+
+	cases := map[string]string{
+		"rules:\n  - cod: [1]\n    result: [fail]\n":  "invalid classifier rules: yaml:",
+		"rules:\n  - code: [1]\n    result: [nope]\n": `invalid classifier rule 0: unknown result "nope"`,
+		"rules: [": "invalid classifier rules: yaml:",
+		"":         "invalid classifier rules: EOF",
+	}
+	for data, expected := range cases {
+		_, err := CompileClassifierRulesYAML([]byte(data), nil)
+		assert.Error(t, err, data)
+		if err != nil {
+			assert.True(t, strings.HasPrefix(err.Error(), expected), err.Error())
+		}
+	}
+}
+
+type testRuleLogger struct {
+	*log.StdLogger
+	lock  sync.Mutex
+	infos []string
+}
+
+func (l *testRuleLogger) Info(v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.infos = append(l.infos, fmt.Sprint(v...))
+}
+
+func (l *testRuleLogger) count() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.infos)
+}
+
+func TestRuleClassifier_yaml(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	write := func(data string, mtime time.Time) {
+		assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		assert.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	now := time.Now()
+	write("rules:\n  - message: busy\n    result: [fail]\n", now.Add(-time.Hour))
+
+	logger := &testRuleLogger{StdLogger: &log.StdLogger{}}
+	c, err := NewRuleClassifier(path, 10*time.Millisecond, nil, WithRuleLogger(logger))
+	assert.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, Fail, c.Classify(errors.New("busy")))
+
+	write("rules:\n  - message: busy\n    result: [fail, retriable]\n", now)
+	for i := 0; i < 100 && logger.count() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, Fail|Retriable, c.Classify(errors.New("busy")))
+	assert.Equal(t, 1, logger.count())
+}
@@ -0,0 +1,156 @@
+package faildep
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BulkheadFullError returns when bulkhead has no free permit and caller can't wait for it,
+// because wait queue is full or wait timeout.
+var BulkheadFullError = fmt.Errorf("Bulkhead Is Full")
+
+// WithBulkheadQueue configure semaphore bulkhead config for every node.
+//
+// Default: semaphore bulkhead is disabled, we must use this OptFunc to enable it.
+//
+// Every node has permits, attempt on node must take a permit first,
+// when no permit is free caller will wait in a FIFO queue until permit is released, maxWait passed or context done.
+// Node whose permits and queue are both full will not be picked.
+//
+// - permits indicate maximum active requests on one node.
+// - queueSize indicate maximum callers waiting for permit of one node.
+// - maxWait indicate maximum time waiting for permit.
+func WithBulkheadQueue(permits int64, queueSize int, maxWait time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.bulkheads = &bulkheads{
+			permits:   permits,
+			queueSize: queueSize,
+			maxWait:   maxWait,
+			sems:      make(map[string]*semaphore),
+		}
+	}
+}
+
+// WithConcurrencyLimit configure semaphore bulkhead config for the whole FailDep.
+//
+// Default: concurrency limit is disabled, we must use this OptFunc to enable it.
+//
+// Every call must take a permit first, and wait in a FIFO queue when no permit is free.
+// Call returns `BulkheadFullError` when queue is full or wait timeout.
+//
+// - maxConcurrent indicate maximum calls running at same time.
+// - queueSize indicate maximum callers waiting for permit.
+// - maxWait indicate maximum time waiting for permit.
+func WithConcurrencyLimit(maxConcurrent int64, queueSize int, maxWait time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.concurrency = newSemaphore(maxConcurrent, queueSize)
+		f.concurrencyMaxWait = maxWait
+	}
+}
+
+type bulkheads struct {
+	lock      sync.Mutex
+	permits   int64
+	queueSize int
+	maxWait   time.Duration
+	sems      map[string]*semaphore
+}
+
+func (b *bulkheads) takeSemaphore(node Resource) *semaphore {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	s, ok := b.sems[node.Server]
+	if !ok {
+		s = newSemaphore(b.permits, b.queueSize)
+		b.sems[node.Server] = s
+	}
+	return s
+}
+
+// full returns whether node can't accept more callers.
+func (b *bulkheads) full(node Resource) bool {
+	return b.takeSemaphore(node).full()
+}
+
+// semaphore present counting semaphore with bounded FIFO wait queue.
+type semaphore struct {
+	lock      sync.Mutex
+	permits   int64
+	used      int64
+	queueSize int
+	waiters   list.List
+}
+
+func newSemaphore(permits int64, queueSize int) *semaphore {
+	return &semaphore{
+		permits:   permits,
+		queueSize: queueSize,
+	}
+}
+
+// acquire takes a permit, waits until permit is released, maxWait passed or ctx is done.
+// maxWait <= 0 means wait until ctx is done.
+func (s *semaphore) acquire(ctx context.Context, maxWait time.Duration) error {
+	s.lock.Lock()
+	if s.used < s.permits && s.waiters.Len() == 0 {
+		s.used++
+		s.lock.Unlock()
+		return nil
+	}
+	if s.waiters.Len() >= s.queueSize {
+		s.lock.Unlock()
+		return BulkheadFullError
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.lock.Unlock()
+
+	var timeout <-chan time.Time
+	if maxWait > 0 {
+		timer := time.NewTimer(maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timeout:
+		err = BulkheadFullError
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	select {
+	case <-ready:
+		// permit has been handed over before giving up.
+		return nil
+	default:
+	}
+	s.waiters.Remove(elem)
+	return err
+}
+
+// release releases a permit and hands it over to the first waiter.
+func (s *semaphore) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if front := s.waiters.Front(); front != nil {
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{}))
+		return
+	}
+	s.used--
+}
+
+// full returns whether no permit is free and wait queue is full.
+func (s *semaphore) full() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.used >= s.permits && s.waiters.Len() >= s.queueSize
+}
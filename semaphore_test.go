@@ -0,0 +1,74 @@
+package faildep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSemaphore_fifoAndQueueFull(t *testing.T) {
+	s := newSemaphore(1, 2)
+	assert.NoError(t, s.acquire(context.Background(), 0))
+
+	var order []int
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, s.acquire(context.Background(), time.Second))
+			lock.Lock()
+			order = append(order, i)
+			lock.Unlock()
+			s.release()
+		}(i)
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, s.full())
+	assert.Equal(t, BulkheadFullError, s.acquire(context.Background(), time.Second))
+
+	s.release()
+	wg.Wait()
+	assert.Equal(t, []int{0, 1}, order)
+	assert.False(t, s.full())
+}
+
+func TestSemaphore_waitTimeoutAndCancel(t *testing.T) {
+	s := newSemaphore(1, 1)
+	assert.NoError(t, s.acquire(context.Background(), 0))
+	assert.Equal(t, BulkheadFullError, s.acquire(context.Background(), 5*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.acquire(ctx, time.Second))
+
+	s.release()
+	assert.NoError(t, s.acquire(context.Background(), 0))
+}
+
+func TestBulkheadQueue_burstQueued(t *testing.T) {
+	f := NewFailDepStatic("testBulkheadQueue", []string{"1"},
+		WithBulkheadQueue(1, 10, time.Second),
+		WithConcurrencyLimit(2, 10, time.Second),
+	)
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- f.Do(func(node *Resource) error {
+				time.Sleep(2 * time.Millisecond)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
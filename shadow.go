@@ -0,0 +1,116 @@
+package faildep
+
+import (
+	"context"
+	"math/rand"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// ShadowTag indicate resource which receive mirrored traffic.
+const ShadowTag = "shadow"
+
+// WithShadow configure shadow traffic mirroring config.
+//
+// Default: Shadow is disabled, resources tagged `shadow` are used as normal resources.
+//
+// Sampled calls will be mirrored to resources tagged `shadow` in background without retry,
+// mirrored result is discarded and only recorded in `ShadowStats`, so primary call is never delayed or affected.
+// Resources tagged `shadow` only receive mirrored calls when shadow is enabled.
+// Call marked by `WithIdempotent(false)` is never mirrored, since mirroring would run it twice.
+//
+// - fraction indicate fraction of calls be mirrored, in [0, 1].
+// - maxConcurrent indicate maximum mirrored calls run at same time, call will not be mirrored when beyond it.
+// - timeout indicate timeout of context given to mirrored call, 0 means no timeout.
+func WithShadow(fraction float64, maxConcurrent int64, timeout time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.shadow = &shadow{
+			fraction:      fraction,
+			maxConcurrent: maxConcurrent,
+			timeout:       timeout,
+		}
+	}
+}
+
+// ShadowStats returns stats of mirrored calls,
+// and how many sampled calls are skipped because of concurrent limit or no available shadow resource.
+func (f *FailDep) ShadowStats() (stats GroupStats, skipped uint64) {
+	if f.shadow == nil {
+		return
+	}
+	return f.shadow.stats.snapshot(), atomic.LoadUint64(&f.shadow.skipped)
+}
+
+type shadow struct {
+	fraction      float64
+	maxConcurrent int64
+	timeout       time.Duration
+	active        int64
+	skipped       uint64
+	stats         groupStats
+}
+
+func (s *shadow) sample() bool {
+	return s.fraction > 0 && rand.Float64() < s.fraction
+}
+
+func (s *shadow) acquire() bool {
+	if atomic.AddInt64(&s.active, 1) > s.maxConcurrent {
+		atomic.AddInt64(&s.active, -1)
+		atomic.AddUint64(&s.skipped, 1)
+		return false
+	}
+	return true
+}
+
+// context returns context given to mirrored call.
+func (s *shadow) context() (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), s.timeout)
+}
+
+func (s *shadow) release() {
+	atomic.AddInt64(&s.active, -1)
+}
+
+// mirror executes service on a shadow resource in background.
+func (f *FailDep) mirror(service serviceFunc) {
+	servers := filterResource(f.metrics.availableServer(f.funcFlags), func(node Resource) bool {
+		return node.HasTag(ShadowTag)
+	})
+	node := f.distributor.srvPicker(&f.metrics, &CallInfo{}, servers)
+	if node == nil {
+		atomic.AddUint64(&f.shadow.skipped, 1)
+		return
+	}
+	if !f.shadow.acquire() {
+		return
+	}
+	go func() {
+		defer f.shadow.release()
+		defer func() {
+			if r := recover(); r != nil {
+				f.logger.Error("res:", f.name, "shadow at:", node.Server, "Panic Occured:", r, string(debug.Stack()))
+			}
+		}()
+		ctx, cancel := f.shadow.context()
+		defer cancel()
+		metric := f.metrics.takeMetric(*node)
+		defer metric.acquireLease(nil).release()
+		startTime := time.Now()
+		result, err := service(ctx, node)
+		rt := time.Now().Sub(startTime)
+		repType := f.classify(result, err).Type
+		discardResult(result)
+		switch {
+		case repType&OK == OK:
+			metric.recordSuccess(rt)
+		case repType&Breakable == Breakable:
+			metric.recordFailure(rt)
+		}
+		f.shadow.stats.record(rt, repType&OK != OK)
+	}()
+}
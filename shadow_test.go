@@ -0,0 +1,62 @@
+package faildep
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestShadow_mirrorWithoutAffectPrimary(t *testing.T) {
+	f := NewFailDepStaticResources("testShadow", ResourceList{
+		{Server: "primary"},
+		{Server: "mirror", Tags: []string{ShadowTag}},
+	}, WithShadow(1, 1, 20*time.Millisecond))
+
+	mirrored := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		startTime := time.Now()
+		err := f.DoContext(context.Background(), func(ctx context.Context, node *Resource) error {
+			if node.Server == "mirror" {
+				<-ctx.Done()
+				mirrored <- struct{}{}
+				return ctx.Err()
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.True(t, time.Now().Sub(startTime) < 10*time.Millisecond)
+	}
+	<-mirrored
+
+	var stats GroupStats
+	var skipped uint64
+	for i := 0; i < 100 && stats.Requests == 0; i++ {
+		time.Sleep(time.Millisecond)
+		stats, skipped = f.ShadowStats()
+	}
+	assert.Equal(t, uint64(1), stats.Requests)
+	assert.Equal(t, uint64(1), stats.Failures)
+	assert.Equal(t, uint64(1), skipped)
+}
+
+func TestShadow_noTimeout(t *testing.T) {
+	f := NewFailDepStaticResources("testShadowNoTimeout", ResourceList{
+		{Server: "primary"},
+		{Server: "shadow", Tags: []string{ShadowTag}},
+	}, WithShadow(1, 1, 0))
+	mirrored := make(chan error, 1)
+	err := f.DoContext(context.Background(), func(ctx context.Context, node *Resource) error {
+		if node.Server == "shadow" {
+			mirrored <- ctx.Err()
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	select {
+	case ctxErr := <-mirrored:
+		assert.NoError(t, ctxErr)
+	case <-time.After(time.Second):
+		t.Fatal("call isn't mirrored")
+	}
+}
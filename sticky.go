@@ -0,0 +1,97 @@
+package faildep
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// WithStickySession configure sticky session config.
+//
+// Default: sticky session is disabled, we must use this OptFunc to enable it.
+//
+// Calls which given `WithAffinityKey` will be sent to the resource served the key last time,
+// and fall back to `ServerPicker` when that resource is tripped, bulkheaded or removed,
+// then the key will be bound to the new picked resource.
+//
+// - capacity indicate maximum keys be remembered, least recently used key will be evicted first.
+// - ttl indicate how long a key be remembered after it's last used.
+func WithStickySession(capacity int, ttl time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.distributor.sticky = newStickyTable(capacity, ttl)
+	}
+}
+
+type stickyEntry struct {
+	key      string
+	server   string
+	expireAt time.Time
+}
+
+type stickyTable struct {
+	lock     sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+func newStickyTable(capacity int, ttl time.Duration) *stickyTable {
+	return &stickyTable{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element, capacity),
+		lru:      list.New(),
+	}
+}
+
+// lookup returns resource bound with key if it's still in given servers.
+func (s *stickyTable) lookup(key string, servers ResourceList) *Resource {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*stickyEntry)
+	now := time.Now()
+	if now.After(entry.expireAt) {
+		s.remove(elem)
+		return nil
+	}
+	for i := range servers {
+		if servers[i].Server == entry.server {
+			entry.expireAt = now.Add(s.ttl)
+			s.lru.MoveToFront(elem)
+			return &servers[i]
+		}
+	}
+	return nil
+}
+
+// bind binds key with given resource.
+func (s *stickyTable) bind(key string, res Resource) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	expireAt := time.Now().Add(s.ttl)
+	if elem, ok := s.entries[key]; ok {
+		entry := elem.Value.(*stickyEntry)
+		entry.server = res.Server
+		entry.expireAt = expireAt
+		s.lru.MoveToFront(elem)
+		return
+	}
+	s.entries[key] = s.lru.PushFront(&stickyEntry{
+		key:      key,
+		server:   res.Server,
+		expireAt: expireAt,
+	})
+	for s.capacity > 0 && s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *stickyTable) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.entries, elem.Value.(*stickyEntry).key)
+}
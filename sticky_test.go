@@ -0,0 +1,57 @@
+package faildep
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStickyTable_evictAndExpire(t *testing.T) {
+	servers := testResources(3)
+	s := newStickyTable(2, 20*time.Millisecond)
+	s.bind("a", servers[0])
+	s.bind("b", servers[1])
+	s.bind("c", servers[2])
+	assert.Nil(t, s.lookup("a", servers))
+	assert.Equal(t, servers[1].Server, s.lookup("b", servers).Server)
+	assert.Equal(t, servers[2].Server, s.lookup("c", servers).Server)
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Nil(t, s.lookup("b", servers))
+}
+
+func TestStickySession_fallbackAndRebind(t *testing.T) {
+	f := NewFailDepStatic("testSticky", []string{"1", "2", "3"},
+		WithStickySession(10, time.Minute),
+		WithCircuitBreaker(1, time.Minute, time.Minute, Exponential),
+	)
+	var first string
+	for i := 0; i < 5; i++ {
+		err := f.Do(func(node *Resource) error {
+			if first == "" {
+				first = node.Server
+			}
+			assert.Equal(t, first, node.Server)
+			return nil
+		}, WithAffinityKey("session"))
+		assert.NoError(t, err)
+	}
+
+	err := f.Do(func(node *Resource) error {
+		return testNetError{}
+	}, WithAffinityKey("session"))
+	assert.Error(t, err)
+
+	var second string
+	for i := 0; i < 5; i++ {
+		err := f.Do(func(node *Resource) error {
+			if second == "" {
+				second = node.Server
+			}
+			assert.Equal(t, second, node.Server)
+			return nil
+		}, WithAffinityKey("session"))
+		assert.NoError(t, err)
+	}
+	assert.NotEqual(t, first, second)
+}
@@ -0,0 +1,94 @@
+package faildep
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// WithSubset configure deterministic subsetting config.
+//
+// Default: Subset is disabled, every client use all resources given by provider.
+//
+// Every client place itself on a ring of resources which ordered by hash of `Server`,
+// and use `subsetSize` successive resources start from its position,
+// so subset is balanced between clients and only changes a little when resources change.
+//
+// - clientID indicate current client's id, it should be in [0, clientCount)
+// - clientCount indicate how many clients use same resource list
+// - subsetSize indicate how many resources will be used by current client
+func WithSubset(clientID, clientCount, subsetSize uint) func(f *FailDep) {
+	return func(f *FailDep) {
+		s := &subsetter{
+			all:         f.metrics.resources,
+			clientID:    clientID,
+			clientCount: clientCount,
+			subsetSize:  subsetSize,
+		}
+		f.metrics.resources = s.resources
+	}
+}
+
+type subsetter struct {
+	lock        sync.Mutex
+	all         func() ResourceList
+	clientID    uint
+	clientCount uint
+	subsetSize  uint
+	lastServers []string
+	lastSubset  ResourceList
+}
+
+func (s *subsetter) resources() ResourceList {
+	all := s.all()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.sameServers(all) {
+		s.lastServers = make([]string, 0, len(all))
+		for _, r := range all {
+			s.lastServers = append(s.lastServers, r.Server)
+		}
+		s.lastSubset = deterministicSubset(all, s.clientID, s.clientCount, s.subsetSize)
+	}
+	return s.lastSubset
+}
+
+func (s *subsetter) sameServers(all ResourceList) bool {
+	if s.lastServers == nil || len(s.lastServers) != len(all) {
+		return false
+	}
+	for i, r := range all {
+		if s.lastServers[i] != r.Server {
+			return false
+		}
+	}
+	return true
+}
+
+func deterministicSubset(resources ResourceList, clientID, clientCount, subsetSize uint) ResourceList {
+	n := uint(len(resources))
+	if clientCount == 0 || subsetSize == 0 || subsetSize >= n {
+		return resources
+	}
+	ring := make(ResourceList, n)
+	copy(ring, resources)
+	sort.Slice(ring, func(i, j int) bool {
+		hi, hj := ringPosition(ring[i].Server), ringPosition(ring[j].Server)
+		if hi != hj {
+			return hi < hj
+		}
+		return ring[i].Server < ring[j].Server
+	})
+	start := (clientID % clientCount) * n / clientCount
+	subset := make(ResourceList, 0, subsetSize)
+	for i := uint(0); i < subsetSize; i++ {
+		subset = append(subset, ring[(start+i)%n])
+	}
+	return subset
+}
+
+func ringPosition(server string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(server))
+	return h.Sum64()
+}
@@ -0,0 +1,73 @@
+package faildep
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func testResources(n int) ResourceList {
+	resources := make(ResourceList, 0, n)
+	for i := 0; i < n; i++ {
+		resources = append(resources, Resource{index: i, Server: fmt.Sprintf("10.0.0.%d:3306", i)})
+	}
+	return resources
+}
+
+func TestSubset_balanced(t *testing.T) {
+	resources := testResources(50)
+	clientCount := uint(200)
+	usage := make(map[string]int)
+	for c := uint(0); c < clientCount; c++ {
+		subset := deterministicSubset(resources, c, clientCount, 10)
+		assert.Len(t, subset, 10)
+		for _, r := range subset {
+			usage[r.Server]++
+		}
+	}
+	assert.Len(t, usage, 50)
+	for _, count := range usage {
+		assert.InDelta(t, 40, count, 1)
+	}
+}
+
+func TestSubset_stableWhenResourceAdded(t *testing.T) {
+	resources := testResources(50)
+	grown := append(testResources(50), Resource{index: 50, Server: "10.0.1.1:3306"})
+	for c := uint(0); c < 20; c++ {
+		before := deterministicSubset(resources, c, 20, 10)
+		after := deterministicSubset(grown, c, 20, 10)
+		kept := 0
+		for _, a := range after {
+			for _, b := range before {
+				if a.Server == b.Server {
+					kept++
+				}
+			}
+		}
+		assert.True(t, kept >= 8, "client", c, "kept", kept)
+	}
+}
+
+func TestSubset_smallList(t *testing.T) {
+	resources := testResources(3)
+	assert.Equal(t, resources, deterministicSubset(resources, 1, 10, 5))
+}
+
+func TestSubset_withFailDep(t *testing.T) {
+	nodes := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		nodes = append(nodes, fmt.Sprintf("node%d", i))
+	}
+	f := NewFailDepStatic("testSubset", nodes, WithSubset(3, 10, 4))
+	used := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		err := f.Do(func(node *Resource) error {
+			used[node.Server] = true
+			return nil
+		})
+		assert.NoError(t, err)
+	}
+	assert.True(t, len(used) <= 4)
+	assert.Len(t, f.metrics.allServers(), 4)
+}
@@ -0,0 +1,188 @@
+package faildep
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ClientThrottledError returns when request is rejected by client-side adaptive throttling.
+var ClientThrottledError = fmt.Errorf("Request Rejected By Client Throttling")
+
+// throttleBuckets indicate how many buckets trailing window is split into.
+const throttleBuckets = 10
+
+// WithAdaptiveThrottle configure client-side adaptive throttling config.
+// via: https://sre.google/sre-book/handling-overload/#eq2101
+//
+// Default: adaptive throttling is disabled, we must use this OptFunc to enable it.
+//
+// Request will be rejected locally with probability `(requests - k * accepts) / (requests + 1)` in trailing window,
+// attempt is accepted when its response isn't classified as `Breakable`.
+// Rejected call returns `ClientThrottledError`.
+//
+// - k indicate multiplier of accepts, lower k rejects more aggressively, e.g. 2.
+// - window indicate trailing window of requests and accepts, e.g. 2 minutes, window shorter than 10ns is treated as 10ns.
+// - perResource indicate throttle every resource separately instead of the whole FailDep,
+// resource is skipped with its reject probability when picking server, and only requests sent to it are counted.
+func WithAdaptiveThrottle(k float64, window time.Duration, perResource bool) func(f *FailDep) {
+	if window < throttleBuckets {
+		window = throttleBuckets
+	}
+	return func(f *FailDep) {
+		f.throttle = &throttle{
+			k:           k,
+			bucketSize:  window / throttleBuckets,
+			perResource: perResource,
+			windows:     make(map[string]*throttleWindow),
+		}
+	}
+}
+
+// ThrottleStats present adaptive throttling stats.
+type ThrottleStats struct {
+	// Server present resource of stats, it's empty when throttle the whole FailDep.
+	Server string `json:"srv"`
+	// Requests present requests in trailing window, include rejected ones.
+	Requests uint64 `json:"requests"`
+	// Accepts present accepted requests in trailing window.
+	Accepts uint64 `json:"accepts"`
+	// Rejected present total rejected requests, it counts times resource is skipped when throttle per resource.
+	Rejected uint64 `json:"rejected"`
+	// RejectProbability present current reject probability.
+	RejectProbability float64 `json:"rejectProbability"`
+}
+
+// ThrottleStats returns adaptive throttling stats,
+// it returns one stats for each resource when throttle per resource.
+func (f *FailDep) ThrottleStats() []ThrottleStats {
+	if f.throttle == nil {
+		return nil
+	}
+	return f.throttle.stats()
+}
+
+type throttle struct {
+	lock        sync.Mutex
+	k           float64
+	bucketSize  time.Duration
+	perResource bool
+	windows     map[string]*throttleWindow
+}
+
+type throttleBucket struct {
+	id       int64
+	requests uint64
+	accepts  uint64
+}
+
+type throttleWindow struct {
+	buckets  [throttleBuckets]throttleBucket
+	rejected uint64
+}
+
+func (t *throttle) window(node *Resource) *throttleWindow {
+	key := ""
+	if t.perResource && node != nil {
+		key = node.Server
+	}
+	w, ok := t.windows[key]
+	if !ok {
+		w = &throttleWindow{}
+		t.windows[key] = w
+	}
+	return w
+}
+
+func (t *throttle) bucket(w *throttleWindow, now time.Time) *throttleBucket {
+	id := now.UnixNano() / int64(t.bucketSize)
+	b := &w.buckets[id%throttleBuckets]
+	if b.id != id {
+		*b = throttleBucket{id: id}
+	}
+	return b
+}
+
+func (t *throttle) sum(w *throttleWindow, now time.Time) (requests, accepts uint64) {
+	id := now.UnixNano() / int64(t.bucketSize)
+	for _, b := range w.buckets {
+		if b.id > id-throttleBuckets {
+			requests += b.requests
+			accepts += b.accepts
+		}
+	}
+	return
+}
+
+func (t *throttle) rejectProbability(requests, accepts uint64) float64 {
+	p := (float64(requests) - t.k*float64(accepts)) / float64(requests+1)
+	if p < 0 {
+		return 0
+	}
+	return p
+}
+
+// allow returns whether request to node should be sent,
+// node is ignored when throttle the whole FailDep.
+func (t *throttle) allow(node *Resource) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := time.Now()
+	w := t.window(node)
+	p := t.rejectProbability(t.sum(w, now))
+	if p == 0 || rand.Float64() >= p {
+		return true
+	}
+	t.bucket(w, now).requests++
+	w.rejected++
+	return false
+}
+
+// record records sent request and whether it's accepted by node.
+func (t *throttle) record(node *Resource, accepted bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	b := t.bucket(t.window(node), time.Now())
+	b.requests++
+	if accepted {
+		b.accepts++
+	}
+}
+
+// reject returns whether request to resource is rejected when throttle per resource,
+// it only samples reject probability and doesn't count request, request is counted by `record` when it's sent.
+func (t *throttle) reject(node Resource) bool {
+	if !t.perResource {
+		return false
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	p := t.rejectProbability(t.sum(t.window(&node), time.Now()))
+	return p > 0 && rand.Float64() < p
+}
+
+// skipped records resource is skipped because it's rejected by `reject`.
+func (t *throttle) skipped(node Resource) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.window(&node).rejected++
+}
+
+func (t *throttle) stats() []ThrottleStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	now := time.Now()
+	ss := make([]ThrottleStats, 0, len(t.windows))
+	for server, w := range t.windows {
+		requests, accepts := t.sum(w, now)
+		ss = append(ss, ThrottleStats{
+			Server:            server,
+			Requests:          requests,
+			Accepts:           accepts,
+			Rejected:          w.rejected,
+			RejectProbability: t.rejectProbability(requests, accepts),
+		})
+	}
+	return ss
+}
@@ -0,0 +1,79 @@
+package faildep
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveThrottle_rejectWhenOverload(t *testing.T) {
+	f := NewFailDepStatic("testThrottle", []string{"1"},
+		WithAdaptiveThrottle(1.5, time.Minute, false),
+	)
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, f.Do(func(node *Resource) error {
+			return nil
+		}))
+	}
+	rejected := 0
+	for i := 0; i < 200; i++ {
+		err := f.Do(func(node *Resource) error {
+			return testNetError{}
+		})
+		if err == ClientThrottledError {
+			rejected++
+		}
+	}
+	assert.True(t, rejected > 50, rejected)
+	ss := f.ThrottleStats()
+	assert.Len(t, ss, 1)
+	assert.Equal(t, uint64(rejected), ss[0].Rejected)
+	assert.True(t, ss[0].RejectProbability > 0.5, ss[0].RejectProbability)
+}
+
+func TestAdaptiveThrottle_perResource(t *testing.T) {
+	f := NewFailDepStatic("testThrottleRes", []string{"bad", "good"},
+		WithAdaptiveThrottle(1.1, time.Minute, true),
+	)
+	good := 0
+	for i := 0; i < 300; i++ {
+		f.Do(func(node *Resource) error {
+			if node.Server == "bad" {
+				return testNetError{}
+			}
+			good++
+			return nil
+		})
+	}
+	assert.True(t, good > 200, good)
+	assert.Len(t, f.ThrottleStats(), 2)
+}
+
+func TestAdaptiveThrottle_perResourceCountsSentOnly(t *testing.T) {
+	f := NewFailDepStatic("testThrottleResSent", []string{"bad", "good"},
+		WithAdaptiveThrottle(1.1, time.Minute, true),
+	)
+	sent := map[string]uint64{}
+	for i := 0; i < 300; i++ {
+		f.Do(func(node *Resource) error {
+			sent[node.Server]++
+			if node.Server == "bad" {
+				return testNetError{}
+			}
+			return nil
+		})
+	}
+	for _, s := range f.ThrottleStats() {
+		assert.Equal(t, sent[s.Server], s.Requests, s.Server)
+	}
+}
+
+func TestAdaptiveThrottle_shortWindowClamped(t *testing.T) {
+	for _, window := range []time.Duration{-time.Second, 0, 5 * time.Nanosecond} {
+		f := NewFailDepStatic("testThrottleShortWindow", []string{"1"}, WithAdaptiveThrottle(2, window, false))
+		assert.Equal(t, time.Duration(1), f.throttle.bucketSize)
+		assert.NoError(t, f.Do(func(node *Resource) error {
+			return nil
+		}))
+	}
+}
@@ -0,0 +1,84 @@
+package faildep
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AttemptTimeoutError returns when one attempt doesn't finish in attempt timeout.
+// It's always classified as `Fail | Retriable | Breakable`.
+var AttemptTimeoutError = fmt.Errorf("Attempt Timeout")
+
+// WithAttemptTimeout configure timeout of every attempt.
+//
+// Default: attempt timeout is disabled, attempt can run until context given to `DoContext` is done.
+//
+// Service function will be given a context with timeout, and `Do` moves on when attempt timeout,
+// even if function ignores context and keeps running,
+// in which case function still holds active request and bulkhead permit of resource until it returns or its lease is reclaimed.
+//
+// - timeout indicate maximum time of one attempt.
+func WithAttemptTimeout(timeout time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.attemptTimeout = timeout
+	}
+}
+
+// WithCallTimeout configure time budget of one `Do` call, include all attempts and retry backOffs.
+//
+// Default: call timeout is disabled, call can run until context given to `DoContext` is done.
+//
+// - timeout indicate maximum time of one call.
+func WithCallTimeout(timeout time.Duration) func(f *FailDep) {
+	return func(f *FailDep) {
+		f.callTimeout = timeout
+	}
+}
+
+// runAttempt runs service once on node with attempt timeout,
+// lease is released when service returns, even if attempt has timed out,
+// and attempt context stays alive until returned result is released, see `bindCancel`.
+func (f *FailDep) runAttempt(ctx context.Context, l *lease, node *Resource, service serviceFunc) (interface{}, error) {
+	if f.attemptTimeout <= 0 {
+		defer l.release()
+		return f.callService(ctx, node, service)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, f.attemptTimeout)
+	done := make(chan attemptResult, 1)
+	go func() {
+		defer l.release()
+		defer recoverTo(done)
+		result, err := f.callService(attemptCtx, node, service)
+		done <- attemptResult{result: result, err: err}
+	}()
+	var r attemptResult
+	select {
+	case r = <-done:
+	case <-attemptCtx.Done():
+		select {
+		case r = <-done:
+		default:
+			r.err = attemptCtx.Err()
+			go func() {
+				late := <-done
+				if late.panic != nil {
+					f.logger.Error("res:", f.name, "at:", node.Server, "Panic Occured After Timeout:", late.panic.Value, string(late.panic.Stack))
+				}
+				discardResult(late.result)
+				cancel()
+			}()
+		}
+	}
+	if r.panic != nil {
+		cancel()
+		panic(r.panic)
+	}
+	if r.err != nil && ctx.Err() == nil && attemptCtx.Err() == context.DeadlineExceeded {
+		discardResult(r.result)
+		cancel()
+		return nil, AttemptTimeoutError
+	}
+	bindCancel(r.result, cancel)
+	return r.result, r.err
+}
@@ -0,0 +1,88 @@
+package faildep
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAttemptTimeout_moveOn(t *testing.T) {
+	f := NewFailDepStatic("testAttemptTimeout", []string{"hang", "ok"},
+		WithAttemptTimeout(10*time.Millisecond),
+		WithRetry(1, 0, 0, 0, NoBackoff),
+		WithPickServer(func(metrics Metrics, call *CallInfo, servers ResourceList) *Resource {
+			return &servers[0]
+		}),
+	)
+	release := make(chan struct{})
+	err := f.DoContext(context.Background(), func(ctx context.Context, node *Resource) error {
+		if node.Server == "hang" {
+			<-release
+			return nil
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	hang := Resource{Server: "hang"}
+	assert.Equal(t, uint64(1), f.metrics.ActiveRequests(hang))
+	assert.Equal(t, uint64(1), f.metrics.SuccessiveFailures(hang))
+
+	close(release)
+	for i := 0; i < 100 && f.metrics.ActiveRequests(hang) > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, uint64(0), f.metrics.ActiveRequests(hang))
+}
+
+func TestAttemptTimeout_contextAware(t *testing.T) {
+	f := NewFailDepStatic("testAttemptTimeoutCtx", []string{"1"},
+		WithAttemptTimeout(5*time.Millisecond),
+	)
+	err := f.DoContext(context.Background(), func(ctx context.Context, node *Resource) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, AttemptTimeoutError, err)
+}
+
+func TestCallTimeout_capsRetries(t *testing.T) {
+	f := NewFailDepStatic("testCallTimeout", []string{"1", "2"},
+		WithCallTimeout(30*time.Millisecond),
+		WithRetry(100, 100, 5*time.Millisecond, 5*time.Millisecond, Exponential),
+	)
+	startTime := time.Now()
+	err := f.Do(func(node *Resource) error {
+		return testNetError{}
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	var exhausted *ExhaustedError
+	assert.True(t, errors.As(err, &exhausted), err)
+	if exhausted != nil {
+		assert.Equal(t, context.DeadlineExceeded, exhausted.Reason)
+		assert.True(t, len(exhausted.Attempts) > 0)
+		for _, a := range exhausted.Attempts {
+			assert.Equal(t, testNetError{}, a.Err)
+		}
+	}
+	assert.True(t, time.Now().Sub(startTime) < 100*time.Millisecond)
+}
+
+func TestCallTimeout_notBreakable(t *testing.T) {
+	f := NewFailDepStatic("testCallTimeoutNotBreakable", []string{"1"},
+		WithCallTimeout(10*time.Millisecond),
+		WithCircuitBreaker(1, time.Minute, time.Minute, Exponential),
+	)
+	for i := 0; i < 2; i++ {
+		err := f.DoContext(context.Background(), func(ctx context.Context, node *Resource) error {
+			<-ctx.Done()
+			return testNetError{}
+		})
+		assert.Error(t, err)
+	}
+	err := f.Do(func(node *Resource) error {
+		return nil
+	})
+	assert.NoError(t, err)
+}